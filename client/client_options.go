@@ -2,6 +2,9 @@ package client
 
 import (
 	"strings"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Option ...
@@ -21,6 +24,20 @@ func WithRealm(realm string) Option {
 	}
 }
 
+// WithAudiences requests the client-credentials token for the given audiences, using the audience parameter.
+func WithAudiences(audienceConfig config.AudienceConfig) Option {
+	return func(c *WithSecret) {
+		c.audiences = audienceConfig
+	}
+}
+
+// WithResources requests the client-credentials token for the given resources, using the resource parameter (RFC 8707).
+func WithResources(resourceConfig config.AudienceConfig) Option {
+	return func(c *WithSecret) {
+		c.resources = resourceConfig
+	}
+}
+
 // ScopeOption ...
 type ScopeOption func(c *WithSecret)
 
@@ -30,3 +47,16 @@ func WithScope(aud string, auds ...string) ScopeOption {
 		c.scopes = append(auds, aud)
 	}
 }
+
+// WithTracerProvider enables OpenTelemetry tracing of the token requests using the given provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *WithSecret) {
+		c.tracer = tp.Tracer(tracerName)
+	}
+}
+
+func withScopes(scopes []string) ScopeOption {
+	return func(c *WithSecret) {
+		c.scopes = scopes
+	}
+}
@@ -4,10 +4,15 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
 	"sync"
 
 	"github.com/bitrise-io/bitrise-oauth/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
 )
@@ -17,7 +22,8 @@ type AuthProvider interface {
 	ManagedHTTPClient(...HTTPClientOption) *http.Client
 	HTTPClient(...HTTPClientOption) *http.Client
 	TokenSource() oauth2.TokenSource
-	UMATokenSource() UMATokenSource
+	UMATokenSource(...UMATokenSourceOption) UMATokenSource
+	RelayHTTPClient(RelayPolicy, ...HTTPClientOption) *http.Client
 }
 
 var clients sync.Map
@@ -30,6 +36,9 @@ type WithSecret struct {
 	baseURL      string
 	credentials  clientcredentials.Config
 	scopes       []string
+	audiences    config.AudienceConfig
+	resources    config.AudienceConfig
+	tracer       trace.Tracer
 }
 
 // NewWithSecret will return the preconfigured model.
@@ -39,6 +48,7 @@ func NewWithSecret(clientID, clientSecret string, scopeOption ScopeOption, opts
 		realm:        config.Realm,
 		clientID:     clientID,
 		clientSecret: clientSecret,
+		tracer:       noop.NewTracerProvider().Tracer(tracerName),
 	}
 
 	for _, opt := range opts {
@@ -47,32 +57,76 @@ func NewWithSecret(clientID, clientSecret string, scopeOption ScopeOption, opts
 
 	scopeOption(cws)
 
-	cws.credentials = clientcredentials.Config{
-		ClientID:     cws.clientID,
-		ClientSecret: cws.clientSecret,
-		TokenURL:     cws.tokenURL(),
-		Scopes:       cws.scopes,
-	}
+	cws.credentials = cws.clientCredentialsConfig()
 
 	return cws
 }
 
+func (cws *WithSecret) clientCredentialsConfig() clientcredentials.Config {
+	return clientcredentials.Config{
+		ClientID:       cws.clientID,
+		ClientSecret:   cws.clientSecret,
+		TokenURL:       cws.tokenURL(),
+		Scopes:         cws.scopes,
+		EndpointParams: cws.endpointParams(),
+	}
+}
+
+// endpointParams returns the additional token request parameters: the audience and the RFC 8707 resource indicators.
+func (cws *WithSecret) endpointParams() url.Values {
+	params := url.Values{}
+	for _, a := range cws.audiences.All() {
+		params.Add(audience, a)
+	}
+	for _, r := range cws.resources.All() {
+		params.Add(resource, r)
+	}
+
+	if len(params) == 0 {
+		return nil
+	}
+
+	return params
+}
+
 func (cws *WithSecret) tokenURL() string {
-	return fmt.Sprintf("%s/auth/realms/%s/protocol/openid-connect/token", cws.baseURL, cws.realm)
+	return fmt.Sprintf("%s/protocol/openid-connect/token", cws.realmURL())
+}
+
+func (cws *WithSecret) realmURL() string {
+	return fmt.Sprintf("%s/auth/realms/%s", cws.baseURL, cws.realm)
 }
 
 func (cws *WithSecret) uid() string {
-	return strings.Join([]string{cws.clientID, cws.clientSecret, cws.tokenURL(), strings.Join(cws.scopes, "/")}, "-")
+	return strings.Join([]string{cws.clientID, cws.clientSecret, cws.tokenURL(), strings.Join(cws.scopes, "/"),
+		sortedJoin(cws.audiences.All()), sortedJoin(cws.resources.All())}, "-")
+}
+
+func sortedJoin(values []string) string {
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, "/")
 }
 
 // TokenSource returns a token source that refreshes the token only when expires
 func (cws *WithSecret) TokenSource() oauth2.TokenSource {
-	return cws.credentials.TokenSource(context.Background())
+	return oauth2.ReuseTokenSource(nil, cws.newCredentialsTokenSource(context.Background(), cws.credentials))
+}
+
+// newCredentialsTokenSource returns a token source that requests a new token upon each invocation,
+// tracing each token request.
+func (cws *WithSecret) newCredentialsTokenSource(ctx context.Context, creds clientcredentials.Config) *credentialsTokenSource {
+	return &credentialsTokenSource{
+		ctx:    ctx,
+		creds:  creds,
+		tracer: cws.tracer,
+		attrs:  []attribute.KeyValue{issuerAttributeKey.String(cws.realmURL())},
+	}
 }
 
 // UMATokenSource returns an UMA token source.
-func (cws *WithSecret) UMATokenSource() UMATokenSource {
-	return newUMATokenSource(cws.credentials)
+func (cws *WithSecret) UMATokenSource(opts ...UMATokenSourceOption) UMATokenSource {
+	return newUMATokenSource(cws.credentials, cws.tracer, cws.realmURL(), opts...)
 }
 
 // ManagedHTTPClient is a preconfigured http client using in-memory client storage
@@ -85,41 +139,69 @@ func (cws *WithSecret) ManagedHTTPClient(opts ...HTTPClientOption) *http.Client
 
 // HTTPClient is a preconfigured http client
 func (cws *WithSecret) HTTPClient(opts ...HTTPClientOption) *http.Client {
-	creds := clientcredentials.Config{
-		ClientID:     cws.clientID,
-		ClientSecret: cws.clientSecret,
-		TokenURL:     cws.tokenURL(),
-		Scopes:       cws.scopes,
-	}
+	clientOpts := newHTTPClientConfig(opts...)
+
+	client := clientOpts.client()
+	client.Transport = cws.serviceTransport(clientOpts.context, client.Transport)
+
+	return client
+}
 
-	clientOpts := &HTTPClientConfig{
-		context: context.Background(),
+// RelayHTTPClient is a preconfigured http client that forwards the token of the caller, stored in the context
+// of the outgoing request by the middlewares of the service package, instead of or in exchange for the
+// client-credentials token of the service, according to the policy.
+func (cws *WithSecret) RelayHTTPClient(policy RelayPolicy, opts ...HTTPClientOption) *http.Client {
+	clientOpts := newHTTPClientConfig(opts...)
+
+	client := clientOpts.client()
+	origTransport := client.Transport
+	if origTransport == nil {
+		origTransport = http.DefaultTransport
 	}
 
-	for _, opt := range opts {
-		opt(clientOpts)
+	client.Transport = &relayTransport{
+		base:             origTransport,
+		serviceTransport: cws.serviceTransport(clientOpts.context, origTransport),
+		policy:           policy,
+		exchanger:        newTokenExchanger(cws),
 	}
 
-	client := &http.Client{}
-	if clientOpts.baseClient != nil {
-		client = clientOpts.baseClient
+	return client
+}
+
+// serviceTransport authenticates the requests with the client-credentials token of the service.
+// The token requests are sent with the context of the client, but they are traced in the trace of the request.
+func (cws *WithSecret) serviceTransport(ctx context.Context, origTransport http.RoundTripper) http.RoundTripper {
+	creds := cws.clientCredentialsConfig()
+
+	newSrc := func() *reuseTokenSource {
+		return &reuseTokenSource{src: cws.newCredentialsTokenSource(ctx, creds)}
 	}
 
-	origTransport := client.Transport
+	return &invalidTokenRefresherTransport{
+		base: origTransport,
+		tokenSrc: &resettableTokenSource{
+			src:    newSrc(),
+			newSrc: newSrc},
+	}
+}
 
-	resettableTokenSrc := &resettableTokenSource{
-		ctx:   clientOpts.context,
-		src:   creds.TokenSource(clientOpts.context),
-		creds: creds}
+// NewWithSecretFromConfig validates the configuration and returns the preconfigured model.
+// The options are applied after the ones derived from the configuration, so they can override them.
+func NewWithSecretFromConfig(cfg config.ClientConfig, opts ...Option) (AuthProvider, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 
-	invalidTokenRefresherTransport := &invalidTokenRefresherTransport{
-		base: &oauth2.Transport{
-			Source: resettableTokenSrc,
-			Base:   origTransport},
-		tokenSrc: resettableTokenSrc,
+	secret, err := cfg.Secret()
+	if err != nil {
+		return nil, err
 	}
 
-	client.Transport = invalidTokenRefresherTransport
+	cfgOpts := []Option{WithBaseURL(cfg.BaseURL), WithRealm(cfg.Realm)}
+	if len(cfg.Audiences) > 0 {
+		cfgOpts = append(cfgOpts, WithAudiences(cfg.AudienceConfig()))
+	}
 
-	return client
+	return NewWithSecret(cfg.ClientID, secret, withScopes(cfg.Scopes), append(cfgOpts, opts...)...), nil
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"sync"
 	"testing"
@@ -104,6 +105,61 @@ func Test_GivenDifferentClientConfigs_WhenTheManagedHttpClientsAreInstantiated_T
 	}
 }
 
+func Test_GivenDifferentAudiencesAndResources_WhenTheManagedHttpClientsAreInstantiated_ThenExpectNewClientsToBeCreated(t *testing.T) {
+	// Given
+	options := [][]client.Option{
+		{},
+		{client.WithAudiences(config.NewAudienceConfig("aud1"))},
+		{client.WithAudiences(config.NewAudienceConfig("aud2"))},
+		{client.WithResources(config.NewAudienceConfig("aud1"))},
+		{client.WithAudiences(config.NewAudienceConfig("aud1")), client.WithResources(config.NewAudienceConfig("https://api.bitrise.io"))},
+	}
+
+	// When
+	var createdClients []*http.Client
+	for _, opts := range options {
+		c := client.NewWithSecret("audience-test-id", "secret", client.WithScope("scope"), opts...).ManagedHTTPClient()
+		createdClients = append(createdClients, c)
+	}
+
+	sameAudiencesClient := client.NewWithSecret("audience-test-id", "secret", client.WithScope("scope"),
+		client.WithAudiences(config.NewAudienceConfigFromAudiences([]string{"aud1"}))).ManagedHTTPClient()
+
+	// Then
+	for i := 0; i < len(createdClients); i++ {
+		for j := i + 1; j < len(createdClients); j++ {
+			assert.NotSame(t, createdClients[i], createdClients[j])
+		}
+	}
+	assert.Same(t, createdClients[1], sameAudiencesClient)
+}
+
+func Test_GivenAudiencesAndResources_WhenTokenIsAcquired_ThenExpectTheParamsToBeSent(t *testing.T) {
+	// Given
+	var form url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		form = r.PostForm
+
+		w.Header().Add("content-type", "application/json")
+		assert.NoError(t, json.NewEncoder(w).Encode(tokenJSON{AccessToken: "access-token", TokenType: "Bearer", ExpiresIn: 60}))
+	}))
+	defer ts.Close()
+
+	tokenSource := client.NewWithSecret("my-client-id", "my-secret", client.WithScope("scope"),
+		client.WithBaseURL(ts.URL),
+		client.WithAudiences(config.NewAudienceConfig("aud1", "aud2")),
+		client.WithResources(config.NewAudienceConfig("https://api.bitrise.io"))).TokenSource()
+
+	// When
+	_, err := tokenSource.Token()
+
+	// Then
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"aud1", "aud2"}, form["audience"])
+	assert.Equal(t, []string{"https://api.bitrise.io"}, form["resource"])
+}
+
 func async(iCount, jCount int, fn func(int, int)) {
 	var wg sync.WaitGroup
 	wg.Add(iCount * jCount)
@@ -297,3 +353,44 @@ func expectAccessTokenChangeForTest(m *mocks.Client, accessTokenPrefix string, e
 			Once()
 	}
 }
+
+func Test_GivenClientConfig_WhenAuthProviderIsCreated_ThenExpectTheConfigToBeUsed(t *testing.T) {
+	// Given
+	var form url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/auth/realms/my-realm/protocol/openid-connect/token", r.URL.Path)
+		assert.NoError(t, r.ParseForm())
+		form = r.PostForm
+
+		w.Header().Add("content-type", "application/json")
+		assert.NoError(t, json.NewEncoder(w).Encode(tokenJSON{AccessToken: "access-token", TokenType: "Bearer", ExpiresIn: 60}))
+	}))
+	defer ts.Close()
+
+	cfg := config.ClientConfig{
+		ClientID:     "my-client-id",
+		ClientSecret: "my-secret",
+		BaseURL:      ts.URL,
+		Realm:        "my-realm",
+		Scopes:       []string{"app:read", "build:write"},
+		Audiences:    []string{"bitrise-api"},
+	}
+
+	// When
+	authProvider, err := client.NewWithSecretFromConfig(cfg)
+	require.NoError(t, err)
+	_, err = authProvider.TokenSource().Token()
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, "app:read build:write", form.Get("scope"))
+	assert.Equal(t, "bitrise-api", form.Get("audience"))
+}
+
+func Test_GivenInvalidClientConfig_WhenAuthProviderIsCreated_ThenExpectAnError(t *testing.T) {
+	// When
+	_, err := client.NewWithSecretFromConfig(config.ClientConfig{ClientSecret: "my-secret"})
+
+	// Then
+	assert.ErrorContains(t, err, "client_id (OAUTH_CLIENT_ID) is required")
+}
@@ -0,0 +1,189 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrorCode is an OAuth 2.0 error code returned by the token endpoint (RFC 6749, section 5.2).
+type ErrorCode string
+
+// ...
+const (
+	ErrorCodeInvalidRequest         ErrorCode = "invalid_request"
+	ErrorCodeInvalidClient          ErrorCode = "invalid_client"
+	ErrorCodeInvalidGrant           ErrorCode = "invalid_grant"
+	ErrorCodeUnauthorizedClient     ErrorCode = "unauthorized_client"
+	ErrorCodeUnsupportedGrantType   ErrorCode = "unsupported_grant_type"
+	ErrorCodeInvalidScope           ErrorCode = "invalid_scope"
+	ErrorCodeInvalidTarget          ErrorCode = "invalid_target"
+	ErrorCodeAccessDenied           ErrorCode = "access_denied"
+	ErrorCodeServerError            ErrorCode = "server_error"
+	ErrorCodeTemporarilyUnavailable ErrorCode = "temporarily_unavailable"
+)
+
+// Sentinel errors to be used with errors.Is, they match any TokenError with the same Code.
+var (
+	ErrInvalidRequest         = &TokenError{Code: ErrorCodeInvalidRequest}
+	ErrInvalidClient          = &TokenError{Code: ErrorCodeInvalidClient}
+	ErrInvalidGrant           = &TokenError{Code: ErrorCodeInvalidGrant}
+	ErrUnauthorizedClient     = &TokenError{Code: ErrorCodeUnauthorizedClient}
+	ErrUnsupportedGrantType   = &TokenError{Code: ErrorCodeUnsupportedGrantType}
+	ErrInvalidScope           = &TokenError{Code: ErrorCodeInvalidScope}
+	ErrInvalidTarget          = &TokenError{Code: ErrorCodeInvalidTarget}
+	ErrAccessDenied           = &TokenError{Code: ErrorCodeAccessDenied}
+	ErrServerError            = &TokenError{Code: ErrorCodeServerError}
+	ErrTemporarilyUnavailable = &TokenError{Code: ErrorCodeTemporarilyUnavailable}
+)
+
+// TokenError is returned by every token source of the package when the token endpoint rejects the token request.
+type TokenError struct {
+	// Code is the OAuth 2.0 error code, it is empty if the response did not contain one.
+	Code ErrorCode
+	// Description is the human-readable error_description of the response.
+	Description string
+	// URI is the error_uri of the response.
+	URI string
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// RetryAfter is the duration parsed from the Retry-After header of the response, zero if it was not set.
+	RetryAfter time.Duration
+
+	retrieveError *oauth2.RetrieveError
+}
+
+// Error ...
+func (e *TokenError) Error() string {
+	msg := fmt.Sprintf("oauth2: token request failed with status %d", e.StatusCode)
+	if e.Code != "" {
+		msg += fmt.Sprintf(": %q", e.Code)
+	}
+	if e.Description != "" {
+		msg += fmt.Sprintf(" %q", e.Description)
+	}
+
+	return msg
+}
+
+// Is reports whether the target is a TokenError with the same Code.
+func (e *TokenError) Is(target error) bool {
+	t, ok := target.(*TokenError)
+	if !ok {
+		return false
+	}
+
+	return t.Code == e.Code
+}
+
+// Unwrap returns the underlying oauth2.RetrieveError.
+func (e *TokenError) Unwrap() error {
+	if e.retrieveError == nil {
+		return nil
+	}
+
+	return e.retrieveError
+}
+
+// Temporary reports whether the request failed because the authorization server is unavailable,
+// so it might succeed when retried, as opposed to rejected credentials or a bad request.
+func (e *TokenError) Temporary() bool {
+	switch {
+	case e.Code == ErrorCodeServerError, e.Code == ErrorCodeTemporarilyUnavailable:
+		return true
+	case e.StatusCode == http.StatusTooManyRequests, e.StatusCode >= http.StatusInternalServerError:
+		return true
+	default:
+		return false
+	}
+}
+
+// asTokenError converts an oauth2.RetrieveError to a TokenError, other errors are returned as they are.
+func asTokenError(err error) error {
+	var retrieveErr *oauth2.RetrieveError
+	if !errors.As(err, &retrieveErr) {
+		return err
+	}
+
+	tokenErr := &TokenError{
+		Code:          ErrorCode(retrieveErr.ErrorCode),
+		Description:   retrieveErr.ErrorDescription,
+		URI:           retrieveErr.ErrorURI,
+		retrieveError: retrieveErr,
+	}
+
+	if retrieveErr.Response != nil {
+		tokenErr.StatusCode = retrieveErr.Response.StatusCode
+		tokenErr.RetryAfter = parseRetryAfter(retrieveErr.Response.Header.Get("Retry-After"), time.Now())
+	}
+
+	if tokenErr.Code == "" {
+		tokenErr.Code, tokenErr.Description, tokenErr.URI = parseErrorBody(retrieveErr.Response, retrieveErr.Body)
+		retrieveErr.ErrorCode = string(tokenErr.Code)
+		retrieveErr.ErrorDescription = tokenErr.Description
+		retrieveErr.ErrorURI = tokenErr.URI
+	}
+
+	return tokenErr
+}
+
+func newTokenError(response *http.Response, body []byte) *TokenError {
+	return asTokenError(&oauth2.RetrieveError{
+		Response: response,
+		Body:     body,
+	}).(*TokenError)
+}
+
+func parseErrorBody(response *http.Response, body []byte) (ErrorCode, string, string) {
+	var mediaType string
+	if response != nil {
+		mediaType, _, _ = mime.ParseMediaType(response.Header.Get(contentType))
+	}
+
+	if mediaType == formURLEncoded || mediaType == "text/plain" {
+		values, err := url.ParseQuery(string(body))
+		if err == nil {
+			return ErrorCode(values.Get("error")), values.Get("error_description"), values.Get("error_uri")
+		}
+	}
+
+	var errorJSON struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+		ErrorURI         string `json:"error_uri"`
+	}
+	if err := json.Unmarshal(body, &errorJSON); err != nil {
+		return "", "", ""
+	}
+
+	return ErrorCode(errorJSON.Error), errorJSON.ErrorDescription, errorJSON.ErrorURI
+}
+
+// parseRetryAfter parses the Retry-After header value, which is either a number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
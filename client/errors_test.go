@@ -0,0 +1,129 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func Test_GivenErrorResponse_WhenTokenErrorIsCreated_ThenExpectTheFieldsToBeParsed(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+		want        TokenError
+	}{
+		{
+			name:        "JSON body",
+			contentType: "application/json;charset=UTF-8",
+			body:        `{"error":"invalid_client","error_description":"Invalid client credentials","error_uri":"https://auth/errors"}`,
+			want:        TokenError{Code: ErrorCodeInvalidClient, Description: "Invalid client credentials", URI: "https://auth/errors", StatusCode: http.StatusUnauthorized},
+		},
+		{
+			name:        "Form body",
+			contentType: formURLEncoded,
+			body:        "error=invalid_scope&error_description=Invalid+scopes",
+			want:        TokenError{Code: ErrorCodeInvalidScope, Description: "Invalid scopes", StatusCode: http.StatusUnauthorized},
+		},
+		{
+			name:        "Unparseable body",
+			contentType: "text/html",
+			body:        "<html>Bad gateway</html>",
+			want:        TokenError{StatusCode: http.StatusUnauthorized},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given
+			response := &http.Response{
+				StatusCode: http.StatusUnauthorized,
+				Header:     http.Header{contentType: []string{testCase.contentType}},
+				Body:       io.NopCloser(bytes.NewBufferString(testCase.body)),
+			}
+
+			// When
+			_, err := extractResponseBody(response)
+
+			// Then
+			var tokenErr *TokenError
+			require.ErrorAs(t, err, &tokenErr)
+			assert.Equal(t, testCase.want.Code, tokenErr.Code)
+			assert.Equal(t, testCase.want.Description, tokenErr.Description)
+			assert.Equal(t, testCase.want.URI, tokenErr.URI)
+			assert.Equal(t, testCase.want.StatusCode, tokenErr.StatusCode)
+
+			var retrieveErr *oauth2.RetrieveError
+			require.ErrorAs(t, err, &retrieveErr)
+			assert.Equal(t, testCase.body, string(retrieveErr.Body))
+		})
+	}
+}
+
+func Test_GivenTokenError_WhenComparedWithSentinels_ThenExpectTheCodeToBeMatched(t *testing.T) {
+	// Given
+	err := error(&TokenError{Code: ErrorCodeInvalidClient, StatusCode: http.StatusUnauthorized})
+
+	// Then
+	assert.ErrorIs(t, err, ErrInvalidClient)
+	assert.NotErrorIs(t, err, ErrInvalidScope)
+	assert.False(t, err.(*TokenError).Temporary())
+}
+
+func Test_GivenUnavailableTokenEndpoint_WhenTokenIsAcquired_ThenExpectATemporaryTokenError(t *testing.T) {
+	// Given
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	tokenSource := NewWithSecret("client-id", "client-secret", WithScope("scope"), WithBaseURL(ts.URL)).TokenSource()
+
+	// When
+	_, err := tokenSource.Token()
+
+	// Then
+	var tokenErr *TokenError
+	require.True(t, errors.As(err, &tokenErr))
+	assert.True(t, tokenErr.Temporary())
+	assert.Equal(t, http.StatusServiceUnavailable, tokenErr.StatusCode)
+	assert.Equal(t, 2*time.Minute, tokenErr.RetryAfter)
+}
+
+func Test_GivenRejectedCredentials_WhenHTTPClientIsUsed_ThenExpectATokenError(t *testing.T) {
+	// Given
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(contentType, "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, err := w.Write([]byte(`{"error":"invalid_client"}`))
+		assert.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	c := NewWithSecret("client-id", "client-secret", WithScope("scope"), WithBaseURL(ts.URL)).HTTPClient()
+
+	// When
+	_, err := c.Get(ts.URL)
+
+	// Then
+	assert.ErrorIs(t, err, ErrInvalidClient)
+}
+
+func Test_ParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter("30", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-1", now))
+	assert.Equal(t, time.Minute, parseRetryAfter(now.Add(time.Minute).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("invalid", now))
+}
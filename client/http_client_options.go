@@ -14,6 +14,27 @@ type HTTPClientConfig struct {
 	baseClient *http.Client
 }
 
+func newHTTPClientConfig(opts ...HTTPClientOption) *HTTPClientConfig {
+	clientOpts := &HTTPClientConfig{
+		context: context.Background(),
+	}
+
+	for _, opt := range opts {
+		opt(clientOpts)
+	}
+
+	return clientOpts
+}
+
+// client returns the base client if it was set, otherwise a new client.
+func (c *HTTPClientConfig) client() *http.Client {
+	if c.baseClient != nil {
+		return c.baseClient
+	}
+
+	return &http.Client{}
+}
+
 // WithContext ...
 func WithContext(ctx context.Context) HTTPClientOption {
 	return func(c *HTTPClientConfig) {
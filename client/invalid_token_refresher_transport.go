@@ -7,22 +7,30 @@ import (
 )
 
 type invalidTokenRefresherTransport struct {
-	base     *oauth2.Transport
-	tokenSrc oauth2.TokenSource
+	base     http.RoundTripper
+	tokenSrc *resettableTokenSource
 }
 
 func (t *invalidTokenRefresherTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	resp, err := t.base.RoundTrip(req)
+	resp, err := t.roundTrip(req)
 	if err != nil {
 		return resp, err
 	}
 
 	if resp.StatusCode == http.StatusUnauthorized {
-		if resettableTokenSource, ok := t.tokenSrc.(*resettableTokenSource); ok {
-			resettableTokenSource.Reset()
-			return t.base.RoundTrip(req)
-		}
+		t.tokenSrc.Reset()
+		return t.roundTrip(req)
 	}
 
 	return resp, err
 }
+
+// roundTrip authenticates the request with a token requested in the context of the request.
+func (t *invalidTokenRefresherTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	transport := &oauth2.Transport{
+		Source: requestTokenSource{ctx: req.Context(), src: t.tokenSrc},
+		Base:   t.base,
+	}
+
+	return transport.RoundTrip(req)
+}
@@ -1,21 +1,161 @@
 package client
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
-// Permission ...
+const (
+	resourceScopeSeparator = "#"
+	scopeSeparator         = ","
+)
+
+// Permission represents an UMA permission: a resource (referenced by its name or ID) with any number of scopes,
+// or scopes without a resource. Its string form is resource#scope1,scope2, #scope or resource.
 type Permission struct {
-	resourceName       string
-	authorizationScope string
+	resourceName string
+	resourceID   string
+	scopes       []string
+	legacy       bool
 }
 
-// NewPermission ...
+// NewPermission returns a permission for the named resource with a single scope. For backward compatibility
+// it is always sent as resource#scope, even if the resource or the scope is empty, and it is never rejected
+// by Validate. Use NewResourcePermission or NewScopePermission for the validated forms.
 func NewPermission(resourceName, authorizationScope string) Permission {
+	permission := NewResourcePermission(resourceName)
+	if authorizationScope != "" {
+		permission.scopes = []string{authorizationScope}
+	}
+	permission.legacy = true
+
+	return permission
+}
+
+// NewResourcePermission returns a permission for the resource with the given name and scopes.
+func NewResourcePermission(resourceName string, scopes ...string) Permission {
+	return Permission{
+		resourceName: resourceName,
+		scopes:       scopes,
+	}
+}
+
+// NewResourceIDPermission returns a permission for the resource with the given ID and scopes.
+func NewResourceIDPermission(resourceID string, scopes ...string) Permission {
+	return Permission{
+		resourceID: resourceID,
+		scopes:     scopes,
+	}
+}
+
+// NewScopePermission returns a permission for the given scopes of any resource.
+func NewScopePermission(scope string, scopes ...string) Permission {
 	return Permission{
-		resourceName:       resourceName,
-		authorizationScope: authorizationScope,
+		scopes: append([]string{scope}, scopes...),
+	}
+}
+
+// ParsePermission parses a permission from its resource#scope1,scope2 string form.
+// The resource is treated as a resource name.
+func ParsePermission(s string) (Permission, error) {
+	resource, scopes, _ := strings.Cut(strings.TrimSpace(s), resourceScopeSeparator)
+
+	permission := Permission{resourceName: strings.TrimSpace(resource)}
+	if scopes != "" {
+		for _, scope := range strings.Split(scopes, scopeSeparator) {
+			permission.scopes = append(permission.scopes, strings.TrimSpace(scope))
+		}
+	}
+
+	if err := permission.Validate(); err != nil {
+		return Permission{}, fmt.Errorf("invalid permission %q: %w", s, err)
+	}
+
+	return permission, nil
+}
+
+// ParsePermissions parses each permission with ParsePermission.
+func ParsePermissions(permissions []string) ([]Permission, error) {
+	parsed := make([]Permission, 0, len(permissions))
+	for _, s := range permissions {
+		permission, err := ParsePermission(s)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, permission)
+	}
+
+	return parsed, nil
+}
+
+// ResourceName returns the name of the resource, empty if the resource is referenced by its ID or not set.
+func (permission Permission) ResourceName() string {
+	return permission.resourceName
+}
+
+// ResourceID returns the ID of the resource, empty if the resource is referenced by its name or not set.
+func (permission Permission) ResourceID() string {
+	return permission.resourceID
+}
+
+// Scopes returns the scopes of the permission.
+func (permission Permission) Scopes() []string {
+	return permission.scopes
+}
+
+// Validate returns an error if the permission can not be sent to the authorization server.
+func (permission Permission) Validate() error {
+	if permission.legacy {
+		return nil
+	}
+
+	if permission.resourceName != "" && permission.resourceID != "" {
+		return errors.New("resource name and resource ID are mutually exclusive")
+	}
+
+	resource := permission.resource()
+	if resource == "" && len(permission.scopes) == 0 {
+		return errors.New("either a resource or a scope is required")
+	}
+
+	if strings.Contains(resource, resourceScopeSeparator) {
+		return fmt.Errorf("resource %q must not contain %q", resource, resourceScopeSeparator)
 	}
+
+	for _, scope := range permission.scopes {
+		if scope == "" {
+			return errors.New("scopes must not be empty")
+		}
+		if strings.ContainsAny(scope, resourceScopeSeparator+scopeSeparator) {
+			return fmt.Errorf("scope %q must not contain %q or %q", scope, resourceScopeSeparator, scopeSeparator)
+		}
+	}
+
+	return nil
+}
+
+// String returns the permission in its resource#scope1,scope2 form.
+func (permission Permission) String() string {
+	return permission.requestParam()
+}
+
+func (permission Permission) resource() string {
+	if permission.resourceID != "" {
+		return permission.resourceID
+	}
+
+	return permission.resourceName
 }
 
 func (permission Permission) requestParam() string {
-	return fmt.Sprintf("%s#%s", permission.resourceName, permission.authorizationScope)
+	if permission.legacy {
+		return permission.resourceName + resourceScopeSeparator + strings.Join(permission.scopes, scopeSeparator)
+	}
+
+	if len(permission.scopes) == 0 {
+		return permission.resource()
+	}
+
+	return permission.resource() + resourceScopeSeparator + strings.Join(permission.scopes, scopeSeparator)
 }
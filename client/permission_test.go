@@ -0,0 +1,100 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PermissionRequestParam(t *testing.T) {
+	testCases := []struct {
+		name       string
+		permission Permission
+		want       string
+	}{
+		{"Resource with one scope", NewPermission("builds", "read"), "builds#read"},
+		{"Legacy resource without scope", NewPermission("builds", ""), "builds#"},
+		{"Legacy permission without resource and scope", NewPermission("", ""), "#"},
+		{"Resource without scope", NewResourcePermission("builds"), "builds"},
+		{"Resource with multiple scopes", NewResourcePermission("builds", "read", "write"), "builds#read,write"},
+		{"Resource ID", NewResourceIDPermission("7f1c9b6e", "read"), "7f1c9b6e#read"},
+		{"Scopes only", NewScopePermission("read", "write"), "#read,write"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			require.NoError(t, testCase.permission.Validate())
+			assert.Equal(t, testCase.want, testCase.permission.requestParam())
+			assert.Equal(t, testCase.want, testCase.permission.String())
+		})
+	}
+}
+
+func Test_GivenPermissionString_WhenParsed_ThenExpectThePermission(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  Permission
+	}{
+		{"builds#read", NewResourcePermission("builds", "read")},
+		{" builds # read , write ", NewResourcePermission("builds", "read", "write")},
+		{"builds", NewResourcePermission("builds")},
+		{"#read", NewScopePermission("read")},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.input, func(t *testing.T) {
+			// When
+			permission, err := ParsePermission(testCase.input)
+
+			// Then
+			require.NoError(t, err)
+			assert.Equal(t, testCase.want, permission)
+		})
+	}
+}
+
+func Test_GivenInvalidPermissionString_WhenParsed_ThenExpectAnError(t *testing.T) {
+	testCases := []struct {
+		input   string
+		wantErr string
+	}{
+		{"", `invalid permission "": either a resource or a scope is required`},
+		{"#", `invalid permission "#": either a resource or a scope is required`},
+		{"builds#read,", `invalid permission "builds#read,": scopes must not be empty`},
+		{"builds#read#write", `invalid permission "builds#read#write": scope "read#write" must not contain "#" or ","`},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.input, func(t *testing.T) {
+			// When
+			_, err := ParsePermission(testCase.input)
+
+			// Then
+			assert.EqualError(t, err, testCase.wantErr)
+		})
+	}
+}
+
+func Test_GivenPermissionStrings_WhenParsed_ThenExpectThePermissions(t *testing.T) {
+	// When
+	permissions, err := ParsePermissions([]string{"builds#read", "apps"})
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, []Permission{NewResourcePermission("builds", "read"), NewResourcePermission("apps")}, permissions)
+
+	_, err = ParsePermissions([]string{"builds#read", ""})
+	assert.Error(t, err)
+}
+
+func Test_GivenPermissionWithResourceNameAndID_WhenValidated_ThenExpectAnError(t *testing.T) {
+	// Given
+	permission := Permission{resourceName: "builds", resourceID: "7f1c9b6e"}
+
+	// Then
+	assert.EqualError(t, permission.Validate(), "resource name and resource ID are mutually exclusive")
+	assert.Equal(t, "", NewResourceIDPermission("7f1c9b6e").ResourceName())
+	assert.Equal(t, "7f1c9b6e", NewResourceIDPermission("7f1c9b6e").ResourceID())
+	assert.Equal(t, []string{"read"}, NewResourcePermission("builds", "read").Scopes())
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bitrise-io/bitrise-oauth/tokencontext"
+)
+
+// RelayPolicy decides which token authenticates the requests of a relay HTTP client.
+type RelayPolicy int
+
+const (
+	// RelayCallerToken forwards the token of the caller, the request fails with ErrNoCallerToken if there is none.
+	RelayCallerToken RelayPolicy = iota
+	// RelayCallerTokenOrServiceToken forwards the token of the caller, or falls back to the
+	// client-credentials token of the service if there is none.
+	RelayCallerTokenOrServiceToken
+	// ExchangeCallerToken exchanges the token of the caller for a token issued to the service (RFC 8693),
+	// requested for the audiences and resources of the AuthProvider. It falls back to the client-credentials
+	// token of the service if there is no caller token.
+	ExchangeCallerToken
+)
+
+// ErrNoCallerToken is returned by the relay HTTP client if the policy requires a caller token,
+// but the context of the request does not hold one.
+var ErrNoCallerToken = errors.New("no caller token in the request context")
+
+type relayTransport struct {
+	base             http.RoundTripper
+	serviceTransport http.RoundTripper
+	policy           RelayPolicy
+	exchanger        *tokenExchanger
+}
+
+func (t *relayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	callerToken, ok := tokencontext.RawToken(req.Context())
+	if !ok {
+		if t.policy == RelayCallerToken {
+			closeRequestBody(req)
+			return nil, ErrNoCallerToken
+		}
+
+		return t.serviceTransport.RoundTrip(req)
+	}
+
+	if t.policy == ExchangeCallerToken {
+		token, err := t.exchanger.Token(req.Context(), callerToken)
+		if err != nil {
+			closeRequestBody(req)
+			return nil, err
+		}
+		callerToken = token.AccessToken
+	}
+
+	// a RoundTripper must not modify the original request
+	relayedReq := req.Clone(req.Context())
+	relayedReq.Header.Set("Authorization", "Bearer "+callerToken)
+
+	return t.base.RoundTrip(relayedReq)
+}
+
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close() //nolint: errcheck
+	}
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/bitrise-io/bitrise-oauth/tokencontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type relayTestServer struct {
+	*httptest.Server
+	grantTypes    []string
+	subjectTokens []string
+	authHeaders   []string
+	tokenRequests atomic.Int32
+}
+
+func startRelayTestServer(t *testing.T) *relayTestServer {
+	s := &relayTestServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/protocol/openid-connect/token") {
+			s.tokenRequests.Add(1)
+			assert.NoError(t, r.ParseForm())
+			s.grantTypes = append(s.grantTypes, r.PostForm.Get(grantType))
+			s.subjectTokens = append(s.subjectTokens, r.PostForm.Get(subjectToken))
+
+			accessToken := "service-token"
+			if r.PostForm.Get(grantType) == tokenExchangeGrantType {
+				accessToken = "exchanged-" + r.PostForm.Get(subjectToken)
+			}
+
+			w.Header().Add(contentType, "application/json")
+			assert.NoError(t, json.NewEncoder(w).Encode(tokenJSON{AccessToken: accessToken, TokenType: "Bearer", ExpiresIn: 300}))
+			return
+		}
+
+		s.authHeaders = append(s.authHeaders, r.Header.Get("Authorization"))
+	}))
+
+	return s
+}
+
+func sendRelayRequest(t *testing.T, c *http.Client, url, callerToken string) error {
+	ctx := context.Background()
+	if callerToken != "" {
+		ctx = tokencontext.WithRawToken(ctx, callerToken)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+
+	response, err := c.Do(request)
+	if err != nil {
+		return err
+	}
+
+	return response.Body.Close()
+}
+
+func Test_GivenRelayCallerTokenPolicy_WhenRequestsAreSent_ThenExpectTheCallerTokenToBeForwarded(t *testing.T) {
+	// Given
+	ts := startRelayTestServer(t)
+	defer ts.Close()
+
+	c := NewWithSecret("client-id", "client-secret", WithScope("scope"), WithBaseURL(ts.URL)).RelayHTTPClient(RelayCallerToken)
+
+	// When
+	errWithToken := sendRelayRequest(t, c, ts.URL, "caller-token")
+	errWithoutToken := sendRelayRequest(t, c, ts.URL, "")
+
+	// Then
+	require.NoError(t, errWithToken)
+	assert.ErrorIs(t, errWithoutToken, ErrNoCallerToken)
+	assert.Equal(t, []string{"Bearer caller-token"}, ts.authHeaders)
+	assert.Equal(t, int32(0), ts.tokenRequests.Load())
+}
+
+func Test_GivenRelayCallerTokenOrServiceTokenPolicy_WhenRequestsAreSent_ThenExpectTheServiceTokenAsFallback(t *testing.T) {
+	// Given
+	ts := startRelayTestServer(t)
+	defer ts.Close()
+
+	c := NewWithSecret("client-id", "client-secret", WithScope("scope"), WithBaseURL(ts.URL)).RelayHTTPClient(RelayCallerTokenOrServiceToken)
+
+	// When
+	require.NoError(t, sendRelayRequest(t, c, ts.URL, "caller-token"))
+	require.NoError(t, sendRelayRequest(t, c, ts.URL, ""))
+
+	// Then
+	assert.Equal(t, []string{"Bearer caller-token", "Bearer service-token"}, ts.authHeaders)
+	assert.Equal(t, []string{"client_credentials"}, ts.grantTypes)
+}
+
+func Test_GivenExchangeCallerTokenPolicy_WhenRequestsAreSent_ThenExpectTheExchangedTokenToBeSentAndCached(t *testing.T) {
+	// Given
+	ts := startRelayTestServer(t)
+	defer ts.Close()
+
+	c := NewWithSecret("client-id", "client-secret", WithScope("scope"), WithBaseURL(ts.URL),
+		WithAudiences(config.NewAudienceConfig("downstream"))).RelayHTTPClient(ExchangeCallerToken)
+
+	// When
+	require.NoError(t, sendRelayRequest(t, c, ts.URL, "caller-token"))
+	require.NoError(t, sendRelayRequest(t, c, ts.URL, "caller-token"))
+
+	// Then
+	assert.Equal(t, []string{"Bearer exchanged-caller-token", "Bearer exchanged-caller-token"}, ts.authHeaders)
+	assert.Equal(t, []string{tokenExchangeGrantType}, ts.grantTypes)
+	assert.Equal(t, []string{"caller-token"}, ts.subjectTokens)
+}
+
+func Test_GivenExchangeCallerTokenPolicy_WhenTheRelayedRequestIsCancelled_ThenExpectTheExchangeToBeCancelled(t *testing.T) {
+	// Given
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	c := NewWithSecret("client-id", "client-secret", WithScope("scope"), WithBaseURL(ts.URL)).RelayHTTPClient(ExchangeCallerToken)
+	ctx, cancel := context.WithTimeout(tokencontext.WithRawToken(context.Background(), "caller-token"), 50*time.Millisecond)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+
+	// When
+	_, err = c.Do(request)
+
+	// Then
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -4,19 +4,17 @@ import (
 	"context"
 
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/clientcredentials"
 )
 
 type resettableTokenSource struct {
-	creds clientcredentials.Config
-	src   oauth2.TokenSource
-	ctx   context.Context
+	src    *reuseTokenSource
+	newSrc func() *reuseTokenSource
 }
 
-func (rts *resettableTokenSource) Token() (*oauth2.Token, error) {
-	return rts.src.Token()
+func (rts *resettableTokenSource) tokenWithContext(ctx context.Context) (*oauth2.Token, error) {
+	return rts.src.tokenWithContext(ctx)
 }
 
 func (rts *resettableTokenSource) Reset() {
-	rts.src = rts.creds.TokenSource(rts.ctx)
+	rts.src = rts.newSrc()
 }
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	accessTokenType        = "urn:ietf:params:oauth:token-type:access_token"
+
+	subjectToken       = "subject_token"
+	subjectTokenType   = "subject_token_type"
+	requestedTokenType = "requested_token_type"
+
+	tokenExchangeSpanName = "oauth.token_exchange"
+
+	// maxExchangedTokens limits the number of cached exchanged tokens
+	maxExchangedTokens = 1000
+	// tokenExchangeTimeout limits the token exchange requests, besides the deadline of the relayed request
+	tokenExchangeTimeout = 30 * time.Second
+)
+
+// tokenExchanger exchanges the tokens of the callers for tokens issued to the service (RFC 8693).
+// The exchanged tokens are cached until they expire, keyed by the hash of the subject token.
+// The exchanges run in the context of the relayed request, so they are cancelled with it and traced in its trace.
+type tokenExchanger struct {
+	client *http.Client
+	config clientcredentials.Config
+	tracer trace.Tracer
+	issuer string
+
+	mu     sync.Mutex
+	tokens map[[sha256.Size]byte]*oauth2.Token
+}
+
+func newTokenExchanger(cws *WithSecret) *tokenExchanger {
+	return &tokenExchanger{
+		client: &http.Client{Timeout: tokenExchangeTimeout},
+		config: cws.clientCredentialsConfig(),
+		tracer: cws.tracer,
+		issuer: cws.realmURL(),
+		tokens: map[[sha256.Size]byte]*oauth2.Token{},
+	}
+}
+
+// Token returns a token issued to the service in exchange for the subject token.
+func (e *tokenExchanger) Token(ctx context.Context, subject string) (*oauth2.Token, error) {
+	key := sha256.Sum256([]byte(subject))
+
+	e.mu.Lock()
+	token, ok := e.tokens[key]
+	e.mu.Unlock()
+
+	if ok && token.Valid() {
+		return token, nil
+	}
+
+	ctx, span := e.tracer.Start(ctx, tokenExchangeSpanName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(issuerAttributeKey.String(e.issuer)))
+	defer span.End()
+
+	token, err := e.exchange(ctx, subject)
+	recordResult(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	e.store(key, token)
+
+	return token, nil
+}
+
+func (e *tokenExchanger) exchange(ctx context.Context, subject string) (*oauth2.Token, error) {
+	v := url.Values{}
+
+	v.Set(grantType, tokenExchangeGrantType)
+	v.Set(subjectToken, subject)
+	v.Set(subjectTokenType, accessTokenType)
+	v.Set(requestedTokenType, accessTokenType)
+	v.Set(clientID, e.config.ClientID)
+	v.Set(clientSecret, e.config.ClientSecret)
+
+	for key, values := range e.config.EndpointParams {
+		for _, value := range values {
+			v.Add(key, value)
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.TokenURL, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set(contentType, formURLEncoded)
+
+	response, err := e.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := extractResponseBody(response)
+	if err != nil {
+		return nil, err
+	}
+
+	return extractTokenFromBody(body, time.Now())
+}
+
+func (e *tokenExchanger) store(key [sha256.Size]byte, token *oauth2.Token) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.tokens) >= maxExchangedTokens {
+		for k, t := range e.tokens {
+			if !t.Valid() {
+				delete(e.tokens, k)
+			}
+		}
+	}
+
+	if len(e.tokens) >= maxExchangedTokens {
+		e.tokens = map[[sha256.Size]byte]*oauth2.Token{}
+	}
+
+	e.tokens[key] = token
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	tracerName = "github.com/bitrise-io/bitrise-oauth/client"
+
+	clientCredentialsSpanName = "oauth.client_credentials.token"
+	umaSpanName               = "oauth.uma.token"
+
+	issuerAttributeKey    = attribute.Key("oauth.issuer")
+	audienceAttributeKey  = attribute.Key("oauth.audience")
+	resultAttributeKey    = attribute.Key("oauth.result")
+	errorTypeAttributeKey = attribute.Key("error.type")
+
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+// credentialsTokenSource fetches a new token upon each invocation, it is wrapped into an oauth2.ReuseTokenSource
+// so the spans are only recorded when a token is actually requested from the authorization server.
+// The errors of the token endpoint are returned as TokenError.
+type credentialsTokenSource struct {
+	ctx    context.Context
+	creds  clientcredentials.Config
+	tracer trace.Tracer
+	attrs  []attribute.KeyValue
+}
+
+func (ts *credentialsTokenSource) Token() (*oauth2.Token, error) {
+	return ts.tokenWithContext(ts.ctx)
+}
+
+// tokenWithContext requests a token with the HTTP context of the token source, but the span is the child of
+// the span in parent (if it has one), like the span of the outgoing request that needs the token.
+func (ts *credentialsTokenSource) tokenWithContext(parent context.Context) (*oauth2.Token, error) {
+	ctx := ts.ctx
+	if parentSpan := trace.SpanFromContext(parent); parentSpan.SpanContext().IsValid() {
+		ctx = trace.ContextWithSpan(ctx, parentSpan)
+	}
+
+	ctx, span := ts.tracer.Start(ctx, clientCredentialsSpanName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(ts.attrs...))
+	defer span.End()
+
+	token, err := ts.creds.Token(ctx)
+	err = asTokenError(err)
+	recordResult(span, err)
+
+	return token, err
+}
+
+// reuseTokenSource returns the token until it expires, like oauth2.ReuseTokenSource, but a new token
+// is requested in the context of the outgoing request that needs it, so the token request joins its trace.
+type reuseTokenSource struct {
+	mu    sync.Mutex
+	token *oauth2.Token
+	src   *credentialsTokenSource
+}
+
+func (s *reuseTokenSource) tokenWithContext(ctx context.Context) (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.Valid() {
+		return s.token, nil
+	}
+
+	token, err := s.src.tokenWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.token = token
+
+	return token, nil
+}
+
+// requestTokenSource binds a context to the token source, so it can be used by an oauth2.Transport.
+type requestTokenSource struct {
+	ctx context.Context
+	src *resettableTokenSource
+}
+
+func (s requestTokenSource) Token() (*oauth2.Token, error) {
+	return s.src.tokenWithContext(s.ctx)
+}
+
+func recordResult(span trace.Span, err error) {
+	if err == nil {
+		span.SetAttributes(resultAttributeKey.String(resultSuccess))
+		return
+	}
+
+	span.SetAttributes(
+		resultAttributeKey.String(resultFailure),
+		errorTypeAttributeKey.String(errorClass(err)),
+	)
+	span.SetStatus(codes.Error, errorClass(err))
+}
+
+// errorClass returns a low cardinality description of the error, that does not contain any sensitive data.
+func errorClass(err error) string {
+	var tokenErr *TokenError
+	if errors.As(err, &tokenErr) {
+		if tokenErr.Code != "" {
+			return string(tokenErr.Code)
+		}
+		return "http_" + strconv.Itoa(tokenErr.StatusCode)
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return "context"
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return "network"
+	}
+
+	return "internal"
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func Test_GivenTracerProvider_WhenTokenIsAcquiredMultipleTimes_ThenExpectOnlyTheTokenRequestToBeTraced(t *testing.T) {
+	// Given
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("content-type", "application/json")
+		assert.NoError(t, json.NewEncoder(w).Encode(tj))
+	}))
+	defer ts.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tokenSource := NewWithSecret("client-id", "client-secret", WithScope("scope"),
+		WithBaseURL(ts.URL),
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))).TokenSource()
+
+	// When
+	for i := 0; i < 3; i++ {
+		_, err := tokenSource.Token()
+		require.NoError(t, err)
+	}
+
+	// Then
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, clientCredentialsSpanName, spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), issuerAttributeKey.String(ts.URL+"/auth/realms/"+config.Realm))
+	assert.Contains(t, spans[0].Attributes(), resultAttributeKey.String(resultSuccess))
+	for _, attr := range spans[0].Attributes() {
+		assert.NotContains(t, attr.Value.Emit(), tj.AccessToken)
+	}
+}
+
+func Test_GivenTracerProvider_WhenUMATokenRequestFails_ThenExpectTheErrorClassToBeRecorded(t *testing.T) {
+	// Given
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tokenSource := NewWithSecret("client-id", "client-secret", WithScope("scope"),
+		WithBaseURL(ts.URL),
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))).UMATokenSource()
+
+	// When
+	_, err := tokenSource.Token(testPayloads, testPermission, audienceConfig)
+
+	// Then
+	require.Error(t, err)
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, umaSpanName, spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), audienceAttributeKey.StringSlice(audienceConfig.All()))
+	assert.Contains(t, spans[0].Attributes(), resultAttributeKey.String(resultFailure))
+	assert.Contains(t, spans[0].Attributes(), errorTypeAttributeKey.String("http_503"))
+}
+
+func Test_GivenParentSpan_WhenServiceTokenIsAcquiredForAnOutgoingRequest_ThenExpectTheTokenRequestToJoinTheTrace(t *testing.T) {
+	// Given
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("content-type", "application/json")
+		assert.NoError(t, json.NewEncoder(w).Encode(tj))
+	}))
+	defer ts.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	httpClient := NewWithSecret("client-id", "client-secret", WithScope("scope"),
+		WithBaseURL(ts.URL),
+		WithTracerProvider(tracerProvider)).HTTPClient()
+
+	ctx, parent := tracerProvider.Tracer("test").Start(context.Background(), "parent")
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+
+	// When
+	resp, err := httpClient.Do(request)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	parent.End()
+
+	// Then
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, clientCredentialsSpanName, spans[0].Name())
+	assert.Equal(t, parent.SpanContext().TraceID(), spans[0].SpanContext().TraceID())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+}
+
+func Test_GivenParentSpan_WhenUMATokenIsAcquiredWithContext_ThenExpectTheTokenRequestToJoinTheTrace(t *testing.T) {
+	// Given
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("content-type", "application/json")
+		assert.NoError(t, json.NewEncoder(w).Encode(tj))
+	}))
+	defer ts.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tokenSource := NewWithSecret("client-id", "client-secret", WithScope("scope"),
+		WithBaseURL(ts.URL),
+		WithTracerProvider(tracerProvider)).UMATokenSource()
+
+	ctx, parent := tracerProvider.Tracer("test").Start(context.Background(), "parent")
+
+	// When
+	_, err := tokenSource.TokenWithContext(ctx, testPayloads, testPermission, audienceConfig)
+	parent.End()
+
+	// Then
+	require.NoError(t, err)
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, umaSpanName, spans[0].Name())
+	assert.Equal(t, parent.SpanContext().TraceID(), spans[0].SpanContext().TraceID())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+}
+
+func Test_ErrorClass(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"OAuth error code", &TokenError{Code: ErrorCodeInvalidClient}, "invalid_client"},
+		{"HTTP status", &TokenError{StatusCode: http.StatusBadGateway}, "http_502"},
+		{"Canceled context", context.Canceled, "context"},
+		{"Other error", errors.New("error"), "internal"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.want, errorClass(testCase.err))
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package client
 
 import (
+	"context"
 	b64 "encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +13,9 @@ import (
 	"time"
 
 	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
 )
@@ -19,6 +24,8 @@ const (
 	umaGrantType        = "urn:ietf:params:oauth:grant-type:uma-ticket"
 	umaClaimTokenFormat = "urn:ietf:params:oauth:token-type:jwt"
 
+	umaIDTokenClaimTokenFormat = "urn:ietf:params:oauth:token-type:id_token"
+
 	grantType        = "grant_type"
 	claimToken       = "claim_token"
 	claimTokenFormat = "claim_token_format"
@@ -26,6 +33,7 @@ const (
 	clientSecret     = "client_secret"
 	permission       = "permission"
 	audience         = "audience"
+	resource         = "resource"
 
 	contentType    = "Content-Type"
 	formURLEncoded = "application/x-www-form-urlencoded"
@@ -49,27 +57,63 @@ func (e *tokenJSON) expiry(baseTime time.Time) time.Time {
 // UMATokenSource represents an UMA token source.
 type UMATokenSource interface {
 	Token(payload interface{}, permisson []Permission, audienceConfig config.AudienceConfig) (*oauth2.Token, error)
+	TokenWithContext(ctx context.Context, payload interface{}, permisson []Permission, audienceConfig config.AudienceConfig) (*oauth2.Token, error)
 }
 
 type umaTokenSource struct {
-	config clientcredentials.Config
+	config  clientcredentials.Config
+	tracer  trace.Tracer
+	issuer  string
+	options UMATokenSourceConfig
 }
 
 // NewUMATokenSource returns a new UMA token source.
-func newUMATokenSource(config clientcredentials.Config) umaTokenSource {
+func newUMATokenSource(config clientcredentials.Config, tracer trace.Tracer, issuer string, opts ...UMATokenSourceOption) umaTokenSource {
+	options := UMATokenSourceConfig{
+		claimTokenFormat: umaClaimTokenFormat,
+	}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	return umaTokenSource{
-		config: config,
+		config:  config,
+		tracer:  tracer,
+		issuer:  issuer,
+		options: options,
 	}
 }
 
 // Token returns a new UMA token upon each invocation.
 func (tokenSource umaTokenSource) Token(payload interface{}, permisson []Permission, audienceConfig config.AudienceConfig) (*oauth2.Token, error) {
-	encodedPayload, err := encodePayload(payload)
+	return tokenSource.TokenWithContext(context.Background(), payload, permisson, audienceConfig)
+}
+
+// TokenWithContext returns a new UMA token upon each invocation, the token request is sent with the context,
+// so it is cancelled with it and its span is the child of the span in the context.
+func (tokenSource umaTokenSource) TokenWithContext(ctx context.Context, payload interface{}, permisson []Permission, audienceConfig config.AudienceConfig) (*oauth2.Token, error) {
+	ctx, span := tokenSource.tracer.Start(ctx, umaSpanName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			issuerAttributeKey.String(tokenSource.issuer),
+			audienceAttributeKey.StringSlice(audienceConfig.All()),
+		))
+	defer span.End()
+
+	token, err := tokenSource.token(ctx, payload, permisson, audienceConfig)
+	recordResult(span, err)
+
+	return token, err
+}
+
+func (tokenSource umaTokenSource) token(ctx context.Context, payload interface{}, permisson []Permission, audienceConfig config.AudienceConfig) (*oauth2.Token, error) {
+	encodedPayload, err := tokenSource.claimToken(payload, time.Now())
 	if err != nil {
 		return nil, err
 	}
 
-	request, err := tokenSource.newTokenRequest(encodedPayload, permisson, audienceConfig)
+	request, err := tokenSource.newTokenRequest(ctx, encodedPayload, permisson, audienceConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -92,6 +136,43 @@ func (tokenSource umaTokenSource) Token(payload interface{}, permisson []Permiss
 	return token, nil
 }
 
+func (tokenSource umaTokenSource) claimToken(payload interface{}, now time.Time) (string, error) {
+	switch {
+	case tokenSource.options.claimTokenFormat == umaIDTokenClaimTokenFormat:
+		idToken, ok := payload.(string)
+		if !ok || idToken == "" {
+			return "", fmt.Errorf("the payload has to be a raw ID token string, got %T", payload)
+		}
+		return idToken, nil
+	case tokenSource.options.signingKey != nil:
+		return tokenSource.signPayload(payload, now)
+	default:
+		return encodePayload(payload)
+	}
+}
+
+func (tokenSource umaTokenSource) signPayload(payload interface{}, now time.Time) (string, error) {
+	if tokenSource.options.issuer == "" {
+		return "", errors.New("the issuer of the signed claim token is empty")
+	}
+	if tokenSource.options.ttl <= 0 {
+		return "", fmt.Errorf("the ttl of the signed claim token has to be positive, got %s", tokenSource.options.ttl)
+	}
+
+	signer, err := jose.NewSigner(*tokenSource.options.signingKey, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", fmt.Errorf("failed to create claim token signer: %w", err)
+	}
+
+	registeredClaims := jwt.Claims{
+		Issuer:   tokenSource.options.issuer,
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(tokenSource.options.ttl)),
+	}
+
+	return jwt.Signed(signer).Claims(payload).Claims(registeredClaims).Serialize()
+}
+
 func encodePayload(payload interface{}) (string, error) {
 	bytes, err := json.Marshal(payload)
 	if err != nil {
@@ -101,16 +182,19 @@ func encodePayload(payload interface{}) (string, error) {
 	return b64.StdEncoding.EncodeToString(bytes), nil
 }
 
-func (tokenSource umaTokenSource) newTokenRequest(encodedPayload string, permisson []Permission, audienceConfig config.AudienceConfig) (*http.Request, error) {
+func (tokenSource umaTokenSource) newTokenRequest(ctx context.Context, encodedPayload string, permisson []Permission, audienceConfig config.AudienceConfig) (*http.Request, error) {
 	v := url.Values{}
 
 	v.Set(grantType, umaGrantType)
 	v.Set(claimToken, encodedPayload)
-	v.Set(claimTokenFormat, umaClaimTokenFormat)
+	v.Set(claimTokenFormat, tokenSource.options.claimTokenFormat)
 	v.Set(clientID, tokenSource.config.ClientID)
 	v.Set(clientSecret, tokenSource.config.ClientSecret)
 
 	for _, p := range permisson {
+		if err := p.Validate(); err != nil {
+			return nil, err
+		}
 		v.Add(permission, p.requestParam())
 	}
 
@@ -118,7 +202,7 @@ func (tokenSource umaTokenSource) newTokenRequest(encodedPayload string, permiss
 		v.Add(audience, a)
 	}
 
-	request, err := http.NewRequest(http.MethodPost, tokenSource.config.TokenURL, strings.NewReader(v.Encode()))
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenSource.config.TokenURL, strings.NewReader(v.Encode()))
 	if err != nil {
 		return nil, err
 	}
@@ -145,10 +229,7 @@ func extractResponseBody(response *http.Response) ([]byte, error) {
 	}
 
 	if response.StatusCode < 200 || response.StatusCode > 299 {
-		return nil, &oauth2.RetrieveError{
-			Response: response,
-			Body:     body,
-		}
+		return nil, newTokenError(response, body)
 	}
 
 	return body, nil
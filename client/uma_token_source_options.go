@@ -0,0 +1,39 @@
+package client
+
+import (
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// UMATokenSourceOption ...
+type UMATokenSourceOption func(c *UMATokenSourceConfig)
+
+// UMATokenSourceConfig ...
+type UMATokenSourceConfig struct {
+	claimTokenFormat string
+	signingKey       *jose.SigningKey
+	issuer           string
+	ttl              time.Duration
+}
+
+// WithSignedClaimToken signs the pushed claims as a JWT with the given key, so the authorization server can verify them.
+// The iss, iat and exp claims are set using the issuer and the ttl. The kid header is set when the key is a jose.JSONWebKey.
+// The issuer must not be empty and the ttl must be positive, otherwise Token returns an error.
+func WithSignedClaimToken(key jose.SigningKey, issuer string, ttl time.Duration) UMATokenSourceOption {
+	return func(c *UMATokenSourceConfig) {
+		c.claimTokenFormat = umaClaimTokenFormat
+		c.signingKey = &key
+		c.issuer = issuer
+		c.ttl = ttl
+	}
+}
+
+// WithIDTokenClaimToken pushes an ID token as the claim token (urn:ietf:params:oauth:token-type:id_token).
+// The payload passed to Token has to be the raw ID token string.
+func WithIDTokenClaimToken() UMATokenSourceOption {
+	return func(c *UMATokenSourceConfig) {
+		c.claimTokenFormat = umaIDTokenClaimTokenFormat
+		c.signingKey = nil
+	}
+}
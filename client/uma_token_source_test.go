@@ -2,6 +2,10 @@ package client
 
 import (
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -9,8 +13,11 @@ import (
 	"time"
 
 	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
 	"io"
@@ -37,10 +44,7 @@ var (
 		Param2: []string{"value2"},
 	}
 
-	testPermission = []Permission{Permission{
-		resourceName:       "resourceName",
-		authorizationScope: "scope",
-	}}
+	testPermission = []Permission{NewPermission("resourceName", "scope")}
 
 	audienceConfig = config.NewAudienceConfig(defaultAudience)
 
@@ -83,10 +87,10 @@ func Test_GivenTokenSource_WhenATokenRequestIsCreated_ThenExpectParamsToBeOnTheR
 		ClientSecret: "clientSecret",
 		TokenURL:     "tokenURL",
 		Scopes:       []string{"scope"},
-	})
+	}, noop.NewTracerProvider().Tracer(tracerName), "issuer")
 
 	// When
-	request, err := umaTokenSource.newTokenRequest(expectedEncodedPayload, testPermission, audienceConfig)
+	request, err := umaTokenSource.newTokenRequest(context.Background(), expectedEncodedPayload, testPermission, audienceConfig)
 	require.NoError(t, err)
 	b, err := io.ReadAll(request.Body)
 	body := string(b)
@@ -146,3 +150,120 @@ func Test_GivenSuccessfulTokenResponse_WhenTokenIsExtractedFromBody_ThenExpectTo
 func urlEncodedBodyParam(key, value string) string {
 	return fmt.Sprintf("%s=%s", url.QueryEscape(key), url.QueryEscape(value))
 }
+
+func Test_GivenInvalidPermission_WhenATokenRequestIsCreated_ThenExpectAnError(t *testing.T) {
+	// Given
+	umaTokenSource := newUMATokenSource(clientcredentials.Config{}, noop.NewTracerProvider().Tracer(tracerName), "issuer")
+
+	// When
+	_, err := umaTokenSource.newTokenRequest(context.Background(), expectedEncodedPayload, []Permission{NewScopePermission("read,write")}, audienceConfig)
+
+	// Then
+	assert.EqualError(t, err, `scope "read,write" must not contain "#" or ","`)
+}
+
+func Test_GivenLegacyPermissionWithoutResourceAndScope_WhenATokenRequestIsCreated_ThenExpectTheLegacyFormatToBeSent(t *testing.T) {
+	// Given
+	umaTokenSource := newUMATokenSource(clientcredentials.Config{}, noop.NewTracerProvider().Tracer(tracerName), "issuer")
+
+	// When
+	request, err := umaTokenSource.newTokenRequest(context.Background(), expectedEncodedPayload, []Permission{NewPermission("", "")}, audienceConfig)
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, request.ParseForm())
+	assert.Equal(t, []string{"#"}, request.PostForm[permission])
+}
+
+func Test_GivenSignedClaimTokenOption_WhenClaimTokenIsCreated_ThenExpectAVerifiableJWT(t *testing.T) {
+	// Given
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signingKey := jose.SigningKey{Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: privateKey, KeyID: "kid"}}
+	now := time.Now()
+
+	umaTokenSource := newUMATokenSource(clientcredentials.Config{}, noop.NewTracerProvider().Tracer(tracerName), "issuer",
+		WithSignedClaimToken(signingKey, "my-service", time.Minute))
+
+	// When
+	signedClaimToken, err := umaTokenSource.claimToken(&testPayloads, now)
+	require.NoError(t, err)
+
+	// Then
+	token, err := jwt.ParseSigned(signedClaimToken, []jose.SignatureAlgorithm{jose.ES256})
+	require.NoError(t, err)
+	assert.Equal(t, "kid", token.Headers[0].KeyID)
+
+	var payload testPayload
+	var registeredClaims jwt.Claims
+	require.NoError(t, token.Claims(&privateKey.PublicKey, &payload, &registeredClaims))
+	assert.Equal(t, testPayloads, payload)
+	assert.Equal(t, "my-service", registeredClaims.Issuer)
+	assert.Equal(t, now.Unix(), registeredClaims.IssuedAt.Time().Unix())
+	assert.Equal(t, now.Add(time.Minute).Unix(), registeredClaims.Expiry.Time().Unix())
+}
+
+func Test_GivenSignedClaimTokenOptionWithoutIssuerOrTTL_WhenTheClaimTokenIsCreated_ThenExpectAnError(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signingKey := jose.SigningKey{Algorithm: jose.ES256, Key: privateKey}
+
+	testCases := []struct {
+		name          string
+		issuer        string
+		ttl           time.Duration
+		expectedError string
+	}{
+		{
+			name:          "empty issuer",
+			ttl:           time.Minute,
+			expectedError: "the issuer of the signed claim token is empty",
+		},
+		{
+			name:          "zero ttl",
+			issuer:        "my-service",
+			expectedError: "the ttl of the signed claim token has to be positive, got 0s",
+		},
+		{
+			name:          "negative ttl",
+			issuer:        "my-service",
+			ttl:           -time.Minute,
+			expectedError: "the ttl of the signed claim token has to be positive, got -1m0s",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given
+			umaTokenSource := newUMATokenSource(clientcredentials.Config{}, noop.NewTracerProvider().Tracer(tracerName), "issuer",
+				WithSignedClaimToken(signingKey, testCase.issuer, testCase.ttl))
+
+			// When
+			_, err := umaTokenSource.Token(&testPayloads, testPermission, audienceConfig)
+
+			// Then
+			assert.EqualError(t, err, testCase.expectedError)
+		})
+	}
+}
+
+func Test_GivenIDTokenClaimTokenOption_WhenATokenRequestIsCreated_ThenExpectTheIDTokenToBeSent(t *testing.T) {
+	// Given
+	umaTokenSource := newUMATokenSource(clientcredentials.Config{}, noop.NewTracerProvider().Tracer(tracerName), "issuer",
+		WithIDTokenClaimToken())
+
+	// When
+	idToken, err := umaTokenSource.claimToken("raw-id-token", time.Now())
+	require.NoError(t, err)
+	request, err := umaTokenSource.newTokenRequest(context.Background(), idToken, testPermission, audienceConfig)
+	require.NoError(t, err)
+	b, err := io.ReadAll(request.Body)
+	require.NoError(t, err)
+
+	// Then
+	assert.Contains(t, string(b), urlEncodedBodyParam(claimToken, "raw-id-token"))
+	assert.Contains(t, string(b), urlEncodedBodyParam(claimTokenFormat, umaIDTokenClaimTokenFormat))
+
+	_, err = umaTokenSource.claimToken(&testPayloads, time.Now())
+	assert.EqualError(t, err, "the payload has to be a raw ID token string, got *client.testPayload")
+}
@@ -1,8 +1,30 @@
 package config
 
+import (
+	"fmt"
+	"path"
+)
+
+// AudienceMatchMode defines how the audiences of a token are matched against the expected audiences.
+type AudienceMatchMode string
+
+// The audience match modes.
+const (
+	// AudienceMatchAnyOf accepts the token if it has any of the expected audiences, it is the default mode.
+	AudienceMatchAnyOf AudienceMatchMode = "any_of"
+	// AudienceMatchAllOf accepts the token if it has all the expected audiences, it may have others too.
+	AudienceMatchAllOf AudienceMatchMode = "all_of"
+	// AudienceMatchExactSet accepts the token if its audiences are exactly the expected ones, in any order.
+	AudienceMatchExactSet AudienceMatchMode = "exact_set"
+	// AudienceMatchPattern accepts the token if any of its audiences matches any of the expected audiences as
+	// a path.Match pattern, so "bitrise-api-*" accepts the per-tenant audiences with the "bitrise-api-" prefix.
+	AudienceMatchPattern AudienceMatchMode = "pattern"
+)
+
 // AudienceConfig ...
 type AudienceConfig struct {
 	audience []string
+	mode     AudienceMatchMode
 }
 
 // NewAudienceConfig ...
@@ -23,3 +45,88 @@ func NewAudienceConfigFromAudiences(audiences []string) AudienceConfig {
 func (audienceConfig AudienceConfig) All() []string {
 	return audienceConfig.audience
 }
+
+// WithMatchMode returns a copy of the AudienceConfig that matches the audiences with the mode.
+func (audienceConfig AudienceConfig) WithMatchMode(mode AudienceMatchMode) AudienceConfig {
+	audienceConfig.mode = mode
+	return audienceConfig
+}
+
+// MatchMode returns the audience match mode, AudienceMatchAnyOf if it is not set.
+func (audienceConfig AudienceConfig) MatchMode() AudienceMatchMode {
+	if audienceConfig.mode == "" {
+		return AudienceMatchAnyOf
+	}
+
+	return audienceConfig.mode
+}
+
+// Validate returns an error if the match mode is unknown or a pattern is malformed.
+func (audienceConfig AudienceConfig) Validate() error {
+	switch audienceConfig.MatchMode() {
+	case AudienceMatchAnyOf, AudienceMatchAllOf, AudienceMatchExactSet:
+	case AudienceMatchPattern:
+		for _, pattern := range audienceConfig.audience {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid audience pattern %q: %w", pattern, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown audience match mode %q", audienceConfig.mode)
+	}
+
+	return nil
+}
+
+// Match reports whether the audiences of a token satisfy the expected audiences with the match mode.
+// Every token matches if there are no expected audiences, no token matches with an unknown mode.
+func (audienceConfig AudienceConfig) Match(tokenAudiences []string) bool {
+	if len(audienceConfig.audience) == 0 {
+		return true
+	}
+
+	switch audienceConfig.MatchMode() {
+	case AudienceMatchAnyOf:
+		for _, aud := range audienceConfig.audience {
+			if containsAudience(tokenAudiences, aud) {
+				return true
+			}
+		}
+		return false
+	case AudienceMatchAllOf:
+		for _, aud := range audienceConfig.audience {
+			if !containsAudience(tokenAudiences, aud) {
+				return false
+			}
+		}
+		return true
+	case AudienceMatchExactSet:
+		for _, aud := range tokenAudiences {
+			if !containsAudience(audienceConfig.audience, aud) {
+				return false
+			}
+		}
+		return audienceConfig.WithMatchMode(AudienceMatchAllOf).Match(tokenAudiences)
+	case AudienceMatchPattern:
+		for _, pattern := range audienceConfig.audience {
+			for _, aud := range tokenAudiences {
+				if matched, err := path.Match(pattern, aud); err == nil && matched {
+					return true
+				}
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+func containsAudience(audiences []string, audience string) bool {
+	for _, aud := range audiences {
+		if aud == audience {
+			return true
+		}
+	}
+
+	return false
+}
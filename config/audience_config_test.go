@@ -18,3 +18,40 @@ func Test_NewAudienceConfigFromAudiences(t *testing.T) {
 
 	assert.Equal(t, audiences, config.All())
 }
+
+func Test_AudienceMatch(t *testing.T) {
+	testCases := []struct {
+		name           string
+		mode           AudienceMatchMode
+		expected       []string
+		tokenAudiences []string
+		want           bool
+	}{
+		{"any-of is the default", "", []string{"aud1", "aud2"}, []string{"aud2", "aud3"}, true},
+		{"any-of without a common audience", AudienceMatchAnyOf, []string{"aud1"}, []string{"aud2"}, false},
+		{"all-of with extra audiences", AudienceMatchAllOf, []string{"aud1", "aud2"}, []string{"aud2", "aud3", "aud1"}, true},
+		{"all-of with a missing audience", AudienceMatchAllOf, []string{"aud1", "aud2"}, []string{"aud1"}, false},
+		{"exact-set in another order", AudienceMatchExactSet, []string{"aud1", "aud2"}, []string{"aud2", "aud1"}, true},
+		{"exact-set with an extra audience", AudienceMatchExactSet, []string{"aud1", "aud2"}, []string{"aud1", "aud2", "aud3"}, false},
+		{"pattern with a matching prefix", AudienceMatchPattern, []string{"bitrise-api-*"}, []string{"other", "bitrise-api-tenant1"}, true},
+		{"pattern without a match", AudienceMatchPattern, []string{"bitrise-api-*"}, []string{"bitrise-addons"}, false},
+		{"no expected audiences", AudienceMatchExactSet, nil, []string{"aud1"}, true},
+		{"no token audiences", AudienceMatchAnyOf, []string{"aud1"}, nil, false},
+		{"unknown mode", "some_of", []string{"aud1"}, []string{"aud1"}, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			config := NewAudienceConfigFromAudiences(testCase.expected).WithMatchMode(testCase.mode)
+
+			assert.Equal(t, testCase.want, config.Match(testCase.tokenAudiences))
+		})
+	}
+}
+
+func Test_AudienceConfigValidation(t *testing.T) {
+	assert.NoError(t, NewAudienceConfig("bitrise-api-*").WithMatchMode(AudienceMatchPattern).Validate())
+	assert.EqualError(t, NewAudienceConfig("bitrise-api-[").WithMatchMode(AudienceMatchPattern).Validate(),
+		`invalid audience pattern "bitrise-api-[": syntax error in pattern`)
+	assert.EqualError(t, NewAudienceConfig("aud1").WithMatchMode("some_of").Validate(), `unknown audience match mode "some_of"`)
+}
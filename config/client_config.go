@@ -0,0 +1,106 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ClientConfig holds the parameters of a client.AuthProvider, it can be loaded from environment variables or files.
+// nolint: govet
+type ClientConfig struct {
+	ClientID         string   `env:"OAUTH_CLIENT_ID" yaml:"client_id" json:"client_id"`
+	ClientSecret     string   `env:"OAUTH_CLIENT_SECRET" yaml:"client_secret" json:"client_secret"`
+	ClientSecretFile string   `env:"OAUTH_CLIENT_SECRET_FILE" yaml:"client_secret_file" json:"client_secret_file"`
+	BaseURL          string   `env:"OAUTH_CLIENT_BASE_URL" yaml:"base_url" json:"base_url"`
+	Realm            string   `env:"OAUTH_CLIENT_REALM" yaml:"realm" json:"realm"`
+	Scopes           []string `env:"OAUTH_CLIENT_SCOPES" yaml:"scopes" json:"scopes"`
+	Audiences        []string `env:"OAUTH_CLIENT_AUDIENCES" yaml:"audiences" json:"audiences"`
+}
+
+// LoadClientConfigFromEnv loads the client configuration from the OAUTH_CLIENT_* environment variables.
+// List values (scopes, audiences) are comma separated.
+func LoadClientConfigFromEnv(ctx context.Context) (ClientConfig, error) {
+	var cfg ClientConfig
+	if err := loadFromEnv(ctx, &cfg); err != nil {
+		return ClientConfig{}, err
+	}
+
+	return cfg.withDefaults(), nil
+}
+
+// LoadClientConfigFromFile loads the client configuration from a YAML or JSON file.
+func LoadClientConfigFromFile(path string) (ClientConfig, error) {
+	var cfg ClientConfig
+	if err := loadFromFile(path, &cfg); err != nil {
+		return ClientConfig{}, err
+	}
+
+	return cfg.withDefaults(), nil
+}
+
+func (cfg ClientConfig) withDefaults() ClientConfig {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = BaseURL
+	}
+	if cfg.Realm == "" {
+		cfg.Realm = Realm
+	}
+
+	return cfg
+}
+
+// Validate returns all the problems of the configuration joined into one error, or nil if it is valid.
+func (cfg ClientConfig) Validate() error {
+	var errs []error
+
+	if cfg.ClientID == "" {
+		errs = append(errs, errors.New("client_id (OAUTH_CLIENT_ID) is required"))
+	}
+
+	switch {
+	case cfg.ClientSecret == "" && cfg.ClientSecretFile == "":
+		errs = append(errs, errors.New("either client_secret (OAUTH_CLIENT_SECRET) or client_secret_file (OAUTH_CLIENT_SECRET_FILE) is required"))
+	case cfg.ClientSecret != "" && cfg.ClientSecretFile != "":
+		errs = append(errs, errors.New("client_secret and client_secret_file are mutually exclusive"))
+	}
+
+	if cfg.BaseURL != "" {
+		if err := validateURL("base_url", cfg.BaseURL); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, scope := range cfg.Scopes {
+		if strings.ContainsAny(scope, " \t\n") {
+			errs = append(errs, fmt.Errorf("scope %q must not contain whitespace", scope))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: invalid client configuration: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// Secret returns the client secret, reading it from ClientSecretFile if it is set.
+func (cfg ClientConfig) Secret() (string, error) {
+	if cfg.ClientSecretFile == "" {
+		return cfg.ClientSecret, nil
+	}
+
+	secret, err := os.ReadFile(cfg.ClientSecretFile)
+	if err != nil {
+		return "", fmt.Errorf("config: failed to read client secret file: %w", err)
+	}
+
+	return strings.TrimSpace(string(secret)), nil
+}
+
+// AudienceConfig returns the requested audiences as an AudienceConfig.
+func (cfg ClientConfig) AudienceConfig() AudienceConfig {
+	return NewAudienceConfigFromAudiences(cfg.Audiences)
+}
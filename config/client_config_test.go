@@ -0,0 +1,119 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenEnvironmentVariables_WhenClientConfigIsLoaded_ThenExpectTheValuesToBeSet(t *testing.T) {
+	// Given
+	t.Setenv("OAUTH_CLIENT_ID", "client-id")
+	t.Setenv("OAUTH_CLIENT_SECRET", "client-secret")
+	t.Setenv("OAUTH_CLIENT_SCOPES", "app:read,build:write")
+	t.Setenv("OAUTH_CLIENT_AUDIENCES", "bitrise-api")
+
+	// When
+	cfg, err := LoadClientConfigFromEnv(context.Background())
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, ClientConfig{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		BaseURL:      BaseURL,
+		Realm:        Realm,
+		Scopes:       []string{"app:read", "build:write"},
+		Audiences:    []string{"bitrise-api"},
+	}, cfg)
+	assert.Equal(t, []string{"bitrise-api"}, cfg.AudienceConfig().All())
+}
+
+func Test_GivenYAMLFileWithSecretFile_WhenClientConfigIsLoaded_ThenExpectTheSecretToBeReadFromFile(t *testing.T) {
+	// Given
+	dir := t.TempDir()
+	secretPath := filepath.Join(dir, "secret")
+	require.NoError(t, os.WriteFile(secretPath, []byte("file-secret\n"), 0o600))
+
+	configPath := filepath.Join(dir, "client.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+client_id: client-id
+client_secret_file: `+secretPath+`
+base_url: https://auth.example.com
+realm: my-realm
+scopes: [app:read]
+`), 0o600))
+
+	// When
+	cfg, err := LoadClientConfigFromFile(configPath)
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, "https://auth.example.com", cfg.BaseURL)
+	assert.Equal(t, "my-realm", cfg.Realm)
+
+	secret, err := cfg.Secret()
+	require.NoError(t, err)
+	assert.Equal(t, "file-secret", secret)
+}
+
+func Test_GivenJSONFileWithUnknownField_WhenClientConfigIsLoaded_ThenExpectAnError(t *testing.T) {
+	// Given
+	configPath := filepath.Join(t.TempDir(), "client.json")
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"client_id": "client-id", "clientsecret": "typo"}`), 0o600))
+
+	// When
+	_, err := LoadClientConfigFromFile(configPath)
+
+	// Then
+	assert.ErrorContains(t, err, "clientsecret")
+}
+
+func Test_GivenUnsupportedFileExtension_WhenClientConfigIsLoaded_ThenExpectAnError(t *testing.T) {
+	// When
+	_, err := LoadClientConfigFromFile("client.toml")
+
+	// Then
+	assert.EqualError(t, err, `config: unsupported file extension ".toml", expected .yaml, .yml or .json`)
+}
+
+func Test_ClientConfigValidation(t *testing.T) {
+	testCases := []struct {
+		name    string
+		cfg     ClientConfig
+		wantErr []string
+	}{
+		{
+			name:    "Missing client ID and secret",
+			cfg:     ClientConfig{},
+			wantErr: []string{"client_id (OAUTH_CLIENT_ID) is required", "either client_secret (OAUTH_CLIENT_SECRET) or client_secret_file (OAUTH_CLIENT_SECRET_FILE) is required"},
+		},
+		{
+			name:    "Both secret and secret file",
+			cfg:     ClientConfig{ClientID: "id", ClientSecret: "secret", ClientSecretFile: "/secret"},
+			wantErr: []string{"client_secret and client_secret_file are mutually exclusive"},
+		},
+		{
+			name:    "Relative base URL and invalid scope",
+			cfg:     ClientConfig{ClientID: "id", ClientSecret: "secret", BaseURL: "auth.example.com", Scopes: []string{"app:read build:write"}},
+			wantErr: []string{`base_url must be an absolute http(s) URL, got "auth.example.com"`, `scope "app:read build:write" must not contain whitespace`},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.cfg.Validate()
+
+			require.Error(t, err)
+			for _, want := range testCase.wantErr {
+				assert.ErrorContains(t, err, want)
+			}
+		})
+	}
+}
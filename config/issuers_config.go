@@ -0,0 +1,59 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// IssuersConfig is the list of token issuers accepted by a service.DefaultJwtValidatorRepository,
+// every entry configures the validator of one issuer. It can be loaded from a YAML or JSON file:
+//
+//	issuers:
+//	  - issuer: https://auth.example.com/auth/realms/services
+//	    audiences: [bitrise-api]
+//	  - issuer: https://auth.example.com/auth/realms/addons
+//	    audiences: [addons-api]
+//	    jwks_url: https://auth.example.com/auth/realms/addons/protocol/openid-connect/certs
+type IssuersConfig struct {
+	Issuers []ValidatorConfig `yaml:"issuers" json:"issuers"`
+}
+
+// LoadIssuersConfigFromFile loads the issuer list from a YAML or JSON file.
+func LoadIssuersConfigFromFile(path string) (IssuersConfig, error) {
+	var cfg IssuersConfig
+	if err := loadFromFile(path, &cfg); err != nil {
+		return IssuersConfig{}, err
+	}
+
+	for i := range cfg.Issuers {
+		cfg.Issuers[i] = cfg.Issuers[i].withDefaults()
+	}
+
+	return cfg, nil
+}
+
+// Validate returns all the problems of the issuer list joined into one error, or nil if it is valid.
+// Every entry must have a unique issuer and be a valid ValidatorConfig.
+func (cfg IssuersConfig) Validate() error {
+	var errs []error
+
+	seen := map[string]bool{}
+	for i, issuerCfg := range cfg.Issuers {
+		if issuerCfg.Issuer == "" {
+			errs = append(errs, fmt.Errorf("issuers[%d]: issuer is required", i))
+		} else if seen[issuerCfg.Issuer] {
+			errs = append(errs, fmt.Errorf("issuers[%d]: duplicate issuer %q", i, issuerCfg.Issuer))
+		}
+		seen[issuerCfg.Issuer] = true
+
+		if err := issuerCfg.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("issuers[%d]: %w", i, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: invalid issuers configuration: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenYAMLFile_WhenIssuersConfigIsLoaded_ThenExpectTheIssuersToBeSet(t *testing.T) {
+	// Given
+	configPath := filepath.Join(t.TempDir(), "issuers.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+issuers:
+  - issuer: https://auth.example.com/auth/realms/services
+    audiences: [bitrise-api]
+  - issuer: https://auth.example.com/auth/realms/addons
+    audiences: [addons-api, bitrise]
+    jwks_url: https://auth.example.com/auth/realms/addons/certs
+`), 0o600))
+
+	// When
+	cfg, err := LoadIssuersConfigFromFile(configPath)
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	require.Len(t, cfg.Issuers, 2)
+	assert.Equal(t, "https://auth.example.com/auth/realms/services", cfg.Issuers[0].Issuer)
+	assert.Equal(t, []string{"bitrise-api"}, cfg.Issuers[0].Audiences)
+	assert.Equal(t, BaseURL, cfg.Issuers[0].BaseURL)
+	assert.Equal(t, "https://auth.example.com/auth/realms/addons/certs", cfg.Issuers[1].JWKSURL)
+	assert.Equal(t, []string{"addons-api", "bitrise"}, cfg.Issuers[1].Audiences)
+}
+
+func Test_IssuersConfigValidation(t *testing.T) {
+	// Given
+	cfg := IssuersConfig{Issuers: []ValidatorConfig{
+		{Issuer: "https://auth.example.com/auth/realms/services", Audiences: []string{"bitrise-api"}},
+		{Issuer: "https://auth.example.com/auth/realms/services", Audiences: []string{"bitrise-api"}},
+		{Audiences: []string{"bitrise-api"}},
+		{Issuer: "https://auth.example.com/auth/realms/addons"},
+	}}
+
+	// When
+	err := cfg.Validate()
+
+	// Then
+	require.Error(t, err)
+	assert.ErrorContains(t, err, `issuers[1]: duplicate issuer "https://auth.example.com/auth/realms/services"`)
+	assert.ErrorContains(t, err, "issuers[2]: issuer is required")
+	assert.ErrorContains(t, err, "issuers[3]: config: invalid validator configuration: at least one audience")
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sethvargo/go-envconfig"
+	"gopkg.in/yaml.v3"
+)
+
+func loadFromEnv(ctx context.Context, target interface{}) error {
+	if err := envconfig.Process(ctx, target); err != nil {
+		return fmt.Errorf("config: failed to load from environment: %w", err)
+	}
+
+	return nil
+}
+
+// loadFromFile decodes a YAML or JSON file (based on its extension) into the target.
+// Unknown fields are rejected so typos in the configuration are not silently ignored.
+func loadFromFile(path string, target interface{}) error {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml", ".json":
+	default:
+		return fmt.Errorf("config: unsupported file extension %q, expected .yaml, .yml or .json", ext)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config: failed to open %s: %w", path, err)
+	}
+	defer file.Close() //nolint: errcheck
+
+	// JSON is a subset of YAML, so the same decoder handles both formats
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(target); err != nil {
+		return fmt.Errorf("config: failed to decode %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func validateURL(field, value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("%s is not a valid URL: %w", field, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%s must be an absolute http(s) URL, got %q", field, value)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("%s must contain a host, got %q", field, value)
+	}
+
+	return nil
+}
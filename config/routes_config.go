@@ -0,0 +1,125 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RoutesConfig is the route policy table of a service: every route maps an HTTP method and a path pattern
+// to the authorization it requires. It can be loaded from a YAML or JSON file:
+//
+//	routes:
+//	  - path: /health
+//	    public: true
+//	  - method: GET
+//	    path: /apps/:app_slug
+//	    scopes: [app:read]
+//	  - method: POST
+//	    path: /apps/:app_slug/builds
+//	    permissions:
+//	      - resource: builds
+//	        scopes: [write]
+//	  - path: /me/**
+type RoutesConfig struct {
+	Routes []RouteConfig `yaml:"routes" json:"routes"`
+}
+
+// RouteConfig is the policy of the requests matching the method and the path pattern.
+//
+// The path pattern is matched segment by segment: "*", ":name" and "{name}" match any single segment,
+// "**" as the last segment matches the rest of the path. An empty method or "*" matches every method.
+// The request path is not cleaned, requests with "." or ".." segments match no route.
+// A public route is not authenticated at all, a route without scopes and permissions requires a valid token only.
+type RouteConfig struct {
+	Method      string             `yaml:"method" json:"method"`
+	Path        string             `yaml:"path" json:"path"`
+	Public      bool               `yaml:"public" json:"public"`
+	Scopes      []string           `yaml:"scopes" json:"scopes"`
+	AnyScopes   []string           `yaml:"any_scopes" json:"any_scopes"`
+	Permissions []PermissionConfig `yaml:"permissions" json:"permissions"`
+}
+
+// PermissionConfig requires ALL the scopes in the permission of the resource.
+type PermissionConfig struct {
+	Resource string   `yaml:"resource" json:"resource"`
+	Scopes   []string `yaml:"scopes" json:"scopes"`
+}
+
+// LoadRoutesConfigFromFile loads the route policy table from a YAML or JSON file.
+func LoadRoutesConfigFromFile(path string) (RoutesConfig, error) {
+	var cfg RoutesConfig
+	if err := loadFromFile(path, &cfg); err != nil {
+		return RoutesConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+// Validate returns all the problems of the route policy table joined into one error, or nil if it is valid.
+func (cfg RoutesConfig) Validate() error {
+	var errs []error
+
+	seen := map[string]bool{}
+	for i, route := range cfg.Routes {
+		for _, err := range route.validate() {
+			errs = append(errs, fmt.Errorf("routes[%d]: %w", i, err))
+		}
+
+		method := strings.ToUpper(route.Method)
+		if method == "" {
+			method = "*"
+		}
+		key := method + " " + route.Path
+		if seen[key] {
+			errs = append(errs, fmt.Errorf("routes[%d]: duplicate route %q", i, key))
+		}
+		seen[key] = true
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: invalid routes configuration: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+func (route RouteConfig) validate() []error {
+	var errs []error
+
+	if !validRouteMethod(route.Method) {
+		errs = append(errs, fmt.Errorf("invalid method %q", route.Method))
+	}
+
+	if !strings.HasPrefix(route.Path, "/") {
+		errs = append(errs, fmt.Errorf("path must start with /, got %q", route.Path))
+	} else if i := strings.Index(route.Path, "**"); i >= 0 && (i != len(route.Path)-2 || route.Path[i-1] != '/') {
+		errs = append(errs, fmt.Errorf("** must be the last segment of the path, got %q", route.Path))
+	}
+
+	if route.Public && (len(route.Scopes) > 0 || len(route.AnyScopes) > 0 || len(route.Permissions) > 0) {
+		errs = append(errs, errors.New("a public route can not require scopes or permissions"))
+	}
+
+	for j, permission := range route.Permissions {
+		if permission.Resource == "" {
+			errs = append(errs, fmt.Errorf("permissions[%d]: resource is required", j))
+		}
+		if len(permission.Scopes) == 0 {
+			errs = append(errs, fmt.Errorf("permissions[%d]: at least one scope is required", j))
+		}
+	}
+
+	return errs
+}
+
+func validRouteMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case "", "*", http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
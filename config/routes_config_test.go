@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenYAMLFile_WhenRoutesConfigIsLoaded_ThenExpectTheRoutesToBeSet(t *testing.T) {
+	// Given
+	configPath := filepath.Join(t.TempDir(), "routes.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+routes:
+  - path: /health
+    public: true
+  - method: POST
+    path: /apps/:app_slug/builds
+    scopes: [build:write]
+    permissions:
+      - resource: apps
+        scopes: [read]
+`), 0o600))
+
+	// When
+	cfg, err := LoadRoutesConfigFromFile(configPath)
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, RoutesConfig{Routes: []RouteConfig{
+		{Path: "/health", Public: true},
+		{
+			Method:      "POST",
+			Path:        "/apps/:app_slug/builds",
+			Scopes:      []string{"build:write"},
+			Permissions: []PermissionConfig{{Resource: "apps", Scopes: []string{"read"}}},
+		},
+	}}, cfg)
+}
+
+func Test_RoutesConfigValidation(t *testing.T) {
+	// Given
+	cfg := RoutesConfig{Routes: []RouteConfig{
+		{Path: "/apps"},
+		{Method: "*", Path: "/apps"},
+		{Method: "FETCH", Path: "apps"},
+		{Path: "/apps/**/builds"},
+		{Path: "/health", Public: true, Scopes: []string{"app:read"}},
+		{Path: "/builds", Permissions: []PermissionConfig{{Scopes: []string{"read"}}, {Resource: "builds"}}},
+	}}
+
+	// When
+	err := cfg.Validate()
+
+	// Then
+	require.Error(t, err)
+	assert.ErrorContains(t, err, `routes[1]: duplicate route "* /apps"`)
+	assert.ErrorContains(t, err, `routes[2]: invalid method "FETCH"`)
+	assert.ErrorContains(t, err, `routes[2]: path must start with /, got "apps"`)
+	assert.ErrorContains(t, err, `routes[3]: ** must be the last segment of the path`)
+	assert.ErrorContains(t, err, "routes[4]: a public route can not require scopes or permissions")
+	assert.ErrorContains(t, err, "routes[5]: permissions[0]: resource is required")
+	assert.ErrorContains(t, err, "routes[5]: permissions[1]: at least one scope is required")
+}
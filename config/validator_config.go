@@ -0,0 +1,126 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// ValidatorConfig holds the parameters of a service.Validator, it can be loaded from environment variables or files.
+// Leeway is a pointer so an explicit 0 (no tolerated clock skew) can be told apart from an unset value (the default leeway).
+// nolint: govet
+type ValidatorConfig struct {
+	BaseURL       string            `env:"OAUTH_VALIDATOR_BASE_URL" yaml:"base_url" json:"base_url"`
+	Realm         string            `env:"OAUTH_VALIDATOR_REALM" yaml:"realm" json:"realm"`
+	Issuer        string            `env:"OAUTH_VALIDATOR_ISSUER" yaml:"issuer" json:"issuer"`
+	JWKSURL       string            `env:"OAUTH_VALIDATOR_JWKS_URL" yaml:"jwks_url" json:"jwks_url"`
+	Algorithms    []string          `env:"OAUTH_VALIDATOR_ALGORITHMS" yaml:"algorithms" json:"algorithms"`
+	Audiences     []string          `env:"OAUTH_VALIDATOR_AUDIENCES" yaml:"audiences" json:"audiences"`
+	AudienceMatch AudienceMatchMode `env:"OAUTH_VALIDATOR_AUDIENCE_MATCH" yaml:"audience_match" json:"audience_match"`
+	Timeout       time.Duration     `env:"OAUTH_VALIDATOR_TIMEOUT" yaml:"timeout" json:"timeout"`
+	Leeway        *time.Duration    `env:"OAUTH_VALIDATOR_LEEWAY,noinit" yaml:"leeway" json:"leeway"`
+}
+
+var supportedAlgorithms = map[string]bool{
+	string(jose.RS256): true, string(jose.RS384): true, string(jose.RS512): true,
+	string(jose.PS256): true, string(jose.PS384): true, string(jose.PS512): true,
+	string(jose.ES256): true, string(jose.ES384): true, string(jose.ES512): true,
+	string(jose.EdDSA): true,
+}
+
+// LoadValidatorConfigFromEnv loads the validator configuration from the OAUTH_VALIDATOR_* environment variables.
+// List values (algorithms, audiences) are comma separated, durations use the time.ParseDuration format.
+func LoadValidatorConfigFromEnv(ctx context.Context) (ValidatorConfig, error) {
+	var cfg ValidatorConfig
+	if err := loadFromEnv(ctx, &cfg); err != nil {
+		return ValidatorConfig{}, err
+	}
+
+	return cfg.withDefaults(), nil
+}
+
+// LoadValidatorConfigFromFile loads the validator configuration from a YAML or JSON file.
+func LoadValidatorConfigFromFile(path string) (ValidatorConfig, error) {
+	var cfg ValidatorConfig
+	if err := loadFromFile(path, &cfg); err != nil {
+		return ValidatorConfig{}, err
+	}
+
+	return cfg.withDefaults(), nil
+}
+
+func (cfg ValidatorConfig) withDefaults() ValidatorConfig {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = BaseURL
+	}
+	if cfg.Realm == "" {
+		cfg.Realm = Realm
+	}
+
+	return cfg
+}
+
+// Validate returns all the problems of the configuration joined into one error, or nil if it is valid.
+func (cfg ValidatorConfig) Validate() error {
+	var errs []error
+
+	if len(cfg.Audiences) == 0 {
+		errs = append(errs, errors.New("at least one audience (OAUTH_VALIDATOR_AUDIENCES) is required"))
+	}
+
+	if err := cfg.AudienceConfig().Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	urls := []struct{ field, value string }{
+		{"base_url", cfg.BaseURL},
+		{"issuer", cfg.Issuer},
+		{"jwks_url", cfg.JWKSURL},
+	}
+	for _, u := range urls {
+		if u.value == "" {
+			continue
+		}
+		if err := validateURL(u.field, u.value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, alg := range cfg.Algorithms {
+		if !supportedAlgorithms[alg] {
+			errs = append(errs, fmt.Errorf("unsupported signature algorithm %q", alg))
+		}
+	}
+
+	if cfg.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("timeout must not be negative, got %s", cfg.Timeout))
+	}
+
+	if cfg.Leeway != nil && *cfg.Leeway < 0 {
+		errs = append(errs, fmt.Errorf("leeway must not be negative, got %s", *cfg.Leeway))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: invalid validator configuration: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// SignatureAlgorithms returns the configured algorithms as jose.SignatureAlgorithm values.
+func (cfg ValidatorConfig) SignatureAlgorithms() []jose.SignatureAlgorithm {
+	algs := make([]jose.SignatureAlgorithm, 0, len(cfg.Algorithms))
+	for _, alg := range cfg.Algorithms {
+		algs = append(algs, jose.SignatureAlgorithm(alg))
+	}
+
+	return algs
+}
+
+// AudienceConfig returns the expected audiences as an AudienceConfig.
+func (cfg ValidatorConfig) AudienceConfig() AudienceConfig {
+	return NewAudienceConfigFromAudiences(cfg.Audiences).WithMatchMode(cfg.AudienceMatch)
+}
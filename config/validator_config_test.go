@@ -0,0 +1,99 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenEnvironmentVariables_WhenValidatorConfigIsLoaded_ThenExpectTheValuesToBeSet(t *testing.T) {
+	// Given
+	t.Setenv("OAUTH_VALIDATOR_ISSUER", "https://auth.example.com/auth/realms/master")
+	t.Setenv("OAUTH_VALIDATOR_ALGORITHMS", "RS256,ES256")
+	t.Setenv("OAUTH_VALIDATOR_AUDIENCES", "bitrise-api,bitrise")
+	t.Setenv("OAUTH_VALIDATOR_TIMEOUT", "5s")
+
+	// When
+	cfg, err := LoadValidatorConfigFromEnv(context.Background())
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, "https://auth.example.com/auth/realms/master", cfg.Issuer)
+	assert.Equal(t, []jose.SignatureAlgorithm{jose.RS256, jose.ES256}, cfg.SignatureAlgorithms())
+	assert.Equal(t, []string{"bitrise-api", "bitrise"}, cfg.AudienceConfig().All())
+	assert.Equal(t, 5*time.Second, cfg.Timeout)
+	assert.Nil(t, cfg.Leeway)
+	assert.Equal(t, BaseURL, cfg.BaseURL)
+	assert.Equal(t, Realm, cfg.Realm)
+}
+
+func Test_GivenZeroLeewayInEnvironment_WhenValidatorConfigIsLoaded_ThenExpectTheLeewayToBeSetToZero(t *testing.T) {
+	// Given
+	t.Setenv("OAUTH_VALIDATOR_AUDIENCES", "bitrise-api")
+	t.Setenv("OAUTH_VALIDATOR_LEEWAY", "0s")
+
+	// When
+	cfg, err := LoadValidatorConfigFromEnv(context.Background())
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	require.NotNil(t, cfg.Leeway)
+	assert.Equal(t, time.Duration(0), *cfg.Leeway)
+}
+
+func Test_GivenJSONFile_WhenValidatorConfigIsLoaded_ThenExpectTheValuesToBeSet(t *testing.T) {
+	// Given
+	configPath := filepath.Join(t.TempDir(), "validator.json")
+	require.NoError(t, os.WriteFile(configPath, []byte(`{
+		"jwks_url": "https://auth.example.com/certs",
+		"audiences": ["bitrise-api-*"],
+		"audience_match": "pattern",
+		"timeout": "10s",
+		"leeway": "30s"
+	}`), 0o600))
+
+	// When
+	cfg, err := LoadValidatorConfigFromFile(configPath)
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, "https://auth.example.com/certs", cfg.JWKSURL)
+	assert.Equal(t, 10*time.Second, cfg.Timeout)
+	require.NotNil(t, cfg.Leeway)
+	assert.Equal(t, 30*time.Second, *cfg.Leeway)
+	assert.Equal(t, AudienceMatchPattern, cfg.AudienceConfig().MatchMode())
+}
+
+func Test_ValidatorConfigValidation(t *testing.T) {
+	// Given
+	leeway := -time.Minute
+	cfg := ValidatorConfig{
+		JWKSURL:       "ftp://auth.example.com/certs",
+		Algorithms:    []string{"RS256", "HS256", "none"},
+		Timeout:       -time.Second,
+		AudienceMatch: "some_of",
+		Leeway:        &leeway,
+	}
+
+	// When
+	err := cfg.Validate()
+
+	// Then
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "at least one audience (OAUTH_VALIDATOR_AUDIENCES) is required")
+	assert.ErrorContains(t, err, `jwks_url must be an absolute http(s) URL, got "ftp://auth.example.com/certs"`)
+	assert.ErrorContains(t, err, `unsupported signature algorithm "HS256"`)
+	assert.ErrorContains(t, err, `unsupported signature algorithm "none"`)
+	assert.ErrorContains(t, err, "timeout must not be negative, got -1s")
+	assert.ErrorContains(t, err, "leeway must not be negative, got -1m0s")
+	assert.ErrorContains(t, err, `unknown audience match mode "some_of"`)
+}
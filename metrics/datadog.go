@@ -73,6 +73,30 @@ func (dm *DatadogMetrics) IncrAuthValidationFailedMetric(issuer string) {
 	dm.IncrRaw("bitrise.jwt_auth.validation_failed", []string{"iss:" + issuer}, 1)
 }
 
+func (dm *DatadogMetrics) IncrAuthValidationFailedWithReasonMetric(issuer, reason string) {
+	if issuer == "" {
+		issuer = unknownIssuerId
+	}
+
+	dm.IncrRaw("bitrise.jwt_auth.validation_failed", []string{"iss:" + issuer, "reason:" + reason}, 1)
+}
+
+func (dm *DatadogMetrics) IncrTokenCacheHitMetric(issuer string) {
+	if issuer == "" {
+		issuer = unknownIssuerId
+	}
+
+	dm.IncrRaw("bitrise.jwt_auth.token_cache_hit", []string{"iss:" + issuer}, 1)
+}
+
+func (dm *DatadogMetrics) IncrTokenCacheMissMetric(issuer string) {
+	if issuer == "" {
+		issuer = unknownIssuerId
+	}
+
+	dm.IncrRaw("bitrise.jwt_auth.token_cache_miss", []string{"iss:" + issuer}, 1)
+}
+
 func (dm *DatadogMetrics) Close() error {
 	return dm.rawClient.Close()
 }
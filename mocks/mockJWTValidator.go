@@ -29,25 +29,35 @@ var MockPublicKey = func() jose.JSONWebKey {
 	return jwk
 }
 
+// MockPayload is the payload of MockToken, verified with MockPublicKey.
+var MockPayload = func() json.RawMessage {
+	var payload json.RawMessage
+	if err := MockToken.Claims(MockPublicKey(), &payload); err != nil {
+		panic(err)
+	}
+
+	return payload
+}()
+
 // JWTValidator ...
 type JWTValidator struct {
 	mock.Mock
 }
 
 // ValidateRequest ...
-func (m *JWTValidator) ValidateRequest(r *http.Request) (*jwt.JSONWebToken, error) {
+func (m *JWTValidator) ValidateRequest(r *http.Request) (*jwt.JSONWebToken, json.RawMessage, error) {
 	args := m.Called(r)
-	return args.Get(0).(*jwt.JSONWebToken), args.Error(1)
+	return args.Get(0).(*jwt.JSONWebToken), args.Get(1).(json.RawMessage), args.Error(2)
 }
 
 // GivenSuccessfulJWTValidation ...
 func (m *JWTValidator) GivenSuccessfulJWTValidation() *JWTValidator {
-	m.On("ValidateRequest", mock.Anything).Return(MockToken, nil)
+	m.On("ValidateRequest", mock.Anything).Return(MockToken, MockPayload, nil)
 	return m
 }
 
 // GivenUnsuccessfulJWTValidation ...
 func (m *JWTValidator) GivenUnsuccessfulJWTValidation(err error) *JWTValidator {
-	m.On("ValidateRequest", mock.Anything).Return(&jwt.JSONWebToken{}, err)
+	m.On("ValidateRequest", mock.Anything).Return(&jwt.JSONWebToken{}, json.RawMessage(nil), err)
 	return m
 }
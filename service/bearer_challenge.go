@@ -0,0 +1,210 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// The error codes of RFC 6750 section 3.1.
+const (
+	bearerErrorInvalidRequest    = "invalid_request"
+	bearerErrorInvalidToken      = "invalid_token"
+	bearerErrorInsufficientScope = "insufficient_scope"
+)
+
+const problemDetailsContentType = "application/problem+json"
+
+// bearerErrorDescriptions are the error descriptions sent to the clients by the error kinds,
+// the error texts are not sent, as they may contain internal details.
+var bearerErrorDescriptions = map[error]string{
+	ErrMalformedToken:   "the token is malformed",
+	ErrTokenExpired:     "the token expired",
+	ErrTokenNotYetValid: "the token is not valid yet",
+	ErrInvalidSignature: "the token signature is invalid",
+	ErrUnknownKey:       "the token is signed with an unknown key",
+	ErrIssuerMismatch:   "the token is issued by an unknown issuer",
+	ErrAudienceMismatch: "the token is not issued for this service",
+	ErrJWKSUnavailable:  "the token could not be verified",
+	ErrTokenRevoked:     "the token is revoked",
+
+	ErrRevocationCheckFailed: "the token could not be verified",
+}
+
+// bearerError is the RFC 6750 error response to a failed validation.
+type bearerError struct {
+	status      int
+	code        string
+	description string
+}
+
+// newBearerError classifies the validation error: a request without a bearer token gets 401 without an error code,
+// a malformed Authorization header gets 400 invalid_request, missing scopes or permissions get 403 insufficient_scope
+// and every other error gets 401 invalid_token.
+func newBearerError(r *http.Request, err error) bearerError {
+	if errors.Is(err, ErrNoRoutePolicy) {
+		return bearerError{http.StatusForbidden, bearerErrorInsufficientScope, "the request is not allowed by the route policy"}
+	}
+
+	switch kind := validationErrorKind(err); kind {
+	case ErrInsufficientScope:
+		return bearerError{http.StatusForbidden, bearerErrorInsufficientScope, "the token does not have the required scopes or permissions"}
+	case ErrMissingToken:
+		if r != nil && len(r.Header.Values(authorizationHeader)) > 1 {
+			return bearerError{http.StatusBadRequest, bearerErrorInvalidRequest, "the request has multiple Authorization headers"}
+		}
+		if r != nil && hasBearerScheme(r.Header.Get(authorizationHeader)) {
+			return bearerError{http.StatusBadRequest, bearerErrorInvalidRequest, "the Authorization header is malformed"}
+		}
+		return bearerError{http.StatusUnauthorized, "", "the request has no bearer token"}
+	default:
+		return bearerError{http.StatusUnauthorized, bearerErrorInvalidToken, bearerErrorDescriptions[kind]}
+	}
+}
+
+func hasBearerScheme(header string) bool {
+	scheme, _, _ := strings.Cut(strings.TrimSpace(header), " ")
+	return strings.EqualFold(scheme, bearer)
+}
+
+// challenge returns the WWW-Authenticate header value of the error.
+func (e bearerError) challenge(realm string) string {
+	var params []string
+	if realm != "" {
+		params = append(params, "realm="+quotedString(realm))
+	}
+	if e.code != "" {
+		params = append(params, "error="+quotedString(e.code), "error_description="+quotedString(e.description))
+	}
+
+	if len(params) == 0 {
+		return bearer
+	}
+
+	return bearer + " " + strings.Join(params, ", ")
+}
+
+// quotedString returns s as an RFC 7230 quoted-string: only the double quote and the backslash are escaped,
+// the control characters, which a quoted-string can't hold, are replaced with spaces.
+func quotedString(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case (c < 0x20 && c != '\t') || c == 0x7f:
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+// message is the plain text body of the response.
+func (e bearerError) message() string {
+	if e.code == "" {
+		return e.description
+	}
+
+	return e.code + ": " + e.description
+}
+
+// problemDetails is the RFC 9457 problem details body of the response, the RFC 6750 error code is an extension member.
+type problemDetails struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (e bearerError) problemDetails() problemDetails {
+	return problemDetails{
+		Type:   "about:blank",
+		Title:  http.StatusText(e.status),
+		Status: e.status,
+		Detail: e.description,
+		Error:  e.code,
+	}
+}
+
+// BearerErrorOption ...
+type BearerErrorOption func(c *bearerErrorConfig)
+
+type bearerErrorConfig struct {
+	realm          string
+	problemDetails bool
+}
+
+// WithBearerRealm sets the realm of the WWW-Authenticate challenges.
+func WithBearerRealm(realm string) BearerErrorOption {
+	return func(c *bearerErrorConfig) {
+		c.realm = realm
+	}
+}
+
+// WithProblemDetails makes the error writer respond with an RFC 9457 problem details JSON body instead of plain text.
+func WithProblemDetails() BearerErrorOption {
+	return func(c *bearerErrorConfig) {
+		c.problemDetails = true
+	}
+}
+
+func newBearerErrorConfig(opts []BearerErrorOption) bearerErrorConfig {
+	c := bearerErrorConfig{}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
+
+// NewBearerErrorWriter returns an error writer for WithHTTPErrorWriter that responds with an RFC 6750
+// WWW-Authenticate challenge and the matching status code (400, 401 or 403). It is the default error writer.
+func NewBearerErrorWriter(opts ...BearerErrorOption) func(w http.ResponseWriter, r *http.Request, err error) {
+	c := newBearerErrorConfig(opts)
+
+	return func(w http.ResponseWriter, r *http.Request, err error) {
+		bearerErr := newBearerError(r, err)
+		w.Header().Set("WWW-Authenticate", bearerErr.challenge(c.realm))
+
+		if !c.problemDetails {
+			http.Error(w, bearerErr.message(), bearerErr.status)
+			return
+		}
+
+		body, _ := json.Marshal(bearerErr.problemDetails()) //nolint: errchkjson
+		w.Header().Set("Content-Type", problemDetailsContentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(bearerErr.status)
+		_, _ = w.Write(body)
+	}
+}
+
+// NewEchoBearerErrorWriter returns an error writer for WithContextErrorWriter that sets an RFC 6750
+// WWW-Authenticate challenge and returns an echo.HTTPError with the matching status code (400, 401 or 403),
+// or writes the problem details body. It is the default error writer of the Echo middleware.
+func NewEchoBearerErrorWriter(opts ...BearerErrorOption) func(echo.Context, error) error {
+	c := newBearerErrorConfig(opts)
+
+	return func(ctx echo.Context, err error) error {
+		bearerErr := newBearerError(ctx.Request(), err)
+		ctx.Response().Header().Set("WWW-Authenticate", bearerErr.challenge(c.realm))
+
+		if !c.problemDetails {
+			return echo.NewHTTPError(bearerErr.status, bearerErr.message()).SetInternal(err)
+		}
+
+		body, _ := json.Marshal(bearerErr.problemDetails()) //nolint: errchkjson
+		return ctx.Blob(bearerErr.status, problemDetailsContentType, body)
+	}
+}
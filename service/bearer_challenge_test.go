@@ -0,0 +1,180 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/bitrise-io/go-auth0"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BearerErrorWriter(t *testing.T) {
+	testCases := []struct {
+		name                    string
+		authorizationHeaders    []string
+		err                     error
+		expectedStatusCode      int
+		expectedChallenge       string
+		expectedBodyDoesNotHave string
+	}{
+		{
+			name:               "Given no token then expect a challenge without an error code",
+			err:                auth0.ErrTokenNotFound,
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedChallenge:  `Bearer realm="bitrise"`,
+		},
+		{
+			name:                 "Given another authorization scheme then expect a challenge without an error code",
+			authorizationHeaders: []string{"Basic dXNlcjpwYXNz"},
+			err:                  auth0.ErrTokenNotFound,
+			expectedStatusCode:   http.StatusUnauthorized,
+			expectedChallenge:    `Bearer realm="bitrise"`,
+		},
+		{
+			name:                 "Given an empty bearer token then expect an invalid request",
+			authorizationHeaders: []string{"Bearer "},
+			err:                  auth0.ErrTokenNotFound,
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedChallenge:    `Bearer realm="bitrise", error="invalid_request", error_description="the Authorization header is malformed"`,
+		},
+		{
+			name:                 "Given multiple Authorization headers then expect an invalid request",
+			authorizationHeaders: []string{"Bearer a", "Bearer b"},
+			err:                  auth0.ErrTokenNotFound,
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedChallenge:    `Bearer realm="bitrise", error="invalid_request", error_description="the request has multiple Authorization headers"`,
+		},
+		{
+			name:               "Given an expired token then expect an invalid token",
+			err:                fmt.Errorf("validation failed: %w", jwt.ErrExpired),
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedChallenge:  `Bearer realm="bitrise", error="invalid_token", error_description="the token expired"`,
+		},
+		{
+			name:                    "Given an internal error then expect an invalid token without the error text",
+			err:                     errors.New("failed to fetch https://internal.example.com/certs"),
+			expectedStatusCode:      http.StatusUnauthorized,
+			expectedChallenge:       `Bearer realm="bitrise", error="invalid_token", error_description="the token is malformed"`,
+			expectedBodyDoesNotHave: "internal.example.com",
+		},
+		{
+			name:               "Given missing scopes then expect an insufficient scope",
+			err:                fmt.Errorf("%w: scope app:write is missing from the token", ErrInsufficientScope),
+			expectedStatusCode: http.StatusForbidden,
+			expectedChallenge:  `Bearer realm="bitrise", error="insufficient_scope", error_description="the token does not have the required scopes or permissions"`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, header := range testCase.authorizationHeaders {
+				request.Header.Add(authorizationHeader, header)
+			}
+			opts := []BearerErrorOption{WithBearerRealm("bitrise")}
+
+			// When
+			recorder := httptest.NewRecorder()
+			NewBearerErrorWriter(opts...)(recorder, request, testCase.err)
+
+			problemRecorder := httptest.NewRecorder()
+			NewBearerErrorWriter(append(opts, WithProblemDetails())...)(problemRecorder, request, testCase.err)
+
+			echoRecorder := httptest.NewRecorder()
+			echoErr := NewEchoBearerErrorWriter(opts...)(echo.New().NewContext(request, echoRecorder), testCase.err)
+
+			// Then
+			assert.Equal(t, testCase.expectedStatusCode, recorder.Code)
+			assert.Equal(t, testCase.expectedChallenge, recorder.Header().Get("WWW-Authenticate"))
+			assert.NotContains(t, recorder.Body.String(), testCase.err.Error())
+
+			var problem problemDetails
+			require.NoError(t, json.Unmarshal(problemRecorder.Body.Bytes(), &problem))
+			assert.Equal(t, testCase.expectedStatusCode, problemRecorder.Code)
+			assert.Equal(t, problemDetailsContentType, problemRecorder.Header().Get("Content-Type"))
+			assert.Equal(t, testCase.expectedStatusCode, problem.Status)
+			assert.Equal(t, http.StatusText(testCase.expectedStatusCode), problem.Title)
+			if testCase.expectedBodyDoesNotHave != "" {
+				assert.NotContains(t, problemRecorder.Body.String(), testCase.expectedBodyDoesNotHave)
+			}
+
+			var httpErr *echo.HTTPError
+			require.True(t, errors.As(echoErr, &httpErr))
+			assert.Equal(t, testCase.expectedStatusCode, httpErr.Code)
+			assert.Equal(t, testCase.expectedChallenge, echoRecorder.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func Test_GivenNoRealm_WhenTokenIsMissing_ThenExpectAPlainBearerChallenge(t *testing.T) {
+	// Given
+	recorder := httptest.NewRecorder()
+
+	// When
+	defaultHTTPErrorWriter(recorder, httptest.NewRequest(http.MethodGet, "/", nil), auth0.ErrTokenNotFound)
+
+	// Then
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(t, "Bearer", recorder.Header().Get("WWW-Authenticate"))
+}
+
+func Test_QuotedString(t *testing.T) {
+	testCases := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"Plain text", "bitrise", `"bitrise"`},
+		{"Quote and backslash", `a "b" \c`, `"a \"b\" \\c"`},
+		{"Non-ASCII text", "bitrisé", `"bitrisé"`},
+		{"Tab", "a\tb", "\"a\tb\""},
+		{"Control characters", "a\r\nb", `"a  b"`},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.want, quotedString(testCase.value))
+		})
+	}
+}
+
+func Test_GivenValidatorWithRealm_WhenTheMiddlewaresRespondWithAnError_ThenExpectTheRealmInTheChallenge(t *testing.T) {
+	// Given
+	validator := NewValidator(config.NewAudienceConfig(defaultAudience[0]),
+		WithRealm("my-realm"),
+		withSecretProvider(defaultSecretProvider))
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	e := echo.New()
+	e.Use(validator.EchoMiddlewareFunc())
+	e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	// When
+	recorder := httptest.NewRecorder()
+	validator.Middleware(next).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	handlerFuncRecorder := httptest.NewRecorder()
+	validator.HandlerFunc(next)(handlerFuncRecorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	echoRecorder := httptest.NewRecorder()
+	e.ServeHTTP(echoRecorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	overriddenRecorder := httptest.NewRecorder()
+	validator.Middleware(next, WithHTTPErrorWriter(NewBearerErrorWriter(WithBearerRealm("other-realm")))).
+		ServeHTTP(overriddenRecorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// Then
+	assert.Equal(t, `Bearer realm="my-realm"`, recorder.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, `Bearer realm="my-realm"`, handlerFuncRecorder.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, `Bearer realm="my-realm"`, echoRecorder.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, `Bearer realm="other-realm"`, overriddenRecorder.Header().Get("WWW-Authenticate"))
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/bitrise-io/bitrise-oauth/tokencontext"
+	"github.com/labstack/echo"
+)
+
+type contextKey int
+
+const (
+	tokenContextKey contextKey = iota
+	extractedTokenContextKey
+)
+
+// TokenEchoKey is the key of the validated TokenWithClaims in the echo.Context.
+const TokenEchoKey = "bitrise-oauth.token"
+
+// ContextWithRawToken returns a copy of the context that holds the raw (encoded) token of the caller,
+// see tokencontext.WithRawToken.
+func ContextWithRawToken(ctx context.Context, rawToken string) context.Context {
+	return tokencontext.WithRawToken(ctx, rawToken)
+}
+
+// RawTokenFromContext returns the raw (encoded) token of the caller, stored by the middlewares of the Validator
+// after the token was validated. It can be forwarded to downstream services, see tokencontext.RawToken.
+func RawTokenFromContext(ctx context.Context) (string, bool) {
+	return tokencontext.RawToken(ctx)
+}
+
+// ContextWithToken returns a copy of the context that holds the validated token of the caller.
+func ContextWithToken(ctx context.Context, token TokenWithClaims) context.Context {
+	return context.WithValue(ctx, tokenContextKey, token)
+}
+
+// TokenFromContext returns the validated token of the caller, stored by the middlewares of the Validator,
+// so its claims can be read without validating the request again.
+func TokenFromContext(ctx context.Context) (TokenWithClaims, bool) {
+	token, ok := ctx.Value(tokenContextKey).(TokenWithClaims)
+	return token, ok && token != nil
+}
+
+// TokenFromEcho returns the validated token of the caller, stored by the Echo middleware of the Validator.
+func TokenFromEcho(c echo.Context) (TokenWithClaims, bool) {
+	if token, ok := c.Get(TokenEchoKey).(TokenWithClaims); ok && token != nil {
+		return token, true
+	}
+
+	return TokenFromContext(c.Request().Context())
+}
+
+// requestWithExtractedToken returns a copy of the request that holds the token extracted by the TokenExtractor
+// of the validator, so the key lookup and the verification read the same token.
+func requestWithExtractedToken(r *http.Request, rawToken string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), extractedTokenContextKey, rawToken))
+}
+
+// rawTokenFromRequest returns the token extracted by the validator, or the bearer token of the Authorization header.
+func rawTokenFromRequest(r *http.Request) string {
+	if rawToken, ok := r.Context().Value(extractedTokenContextKey).(string); ok {
+		return rawToken
+	}
+
+	rawToken, _ := AuthorizationHeaderExtractor().ExtractToken(r)
+	return rawToken
+}
+
+// requestWithToken returns a copy of the request whose context holds the validated and the raw token.
+func requestWithToken(r *http.Request, token TokenWithClaims) *http.Request {
+	rawToken := rawTokenFromRequest(r)
+	if t, ok := token.(*tokenWithClaims); ok && t.raw != "" {
+		rawToken = t.raw
+	}
+
+	ctx := ContextWithRawToken(r.Context(), rawToken)
+	return r.WithContext(ContextWithToken(ctx, token))
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenContextWithRawToken_WhenRawTokenIsRead_ThenExpectTheToken(t *testing.T) {
+	// Given
+	ctx := ContextWithRawToken(context.Background(), "raw-token")
+
+	// When
+	rawToken, ok := RawTokenFromContext(ctx)
+
+	// Then
+	assert.True(t, ok)
+	assert.Equal(t, "raw-token", rawToken)
+
+	_, ok = RawTokenFromContext(context.Background())
+	assert.False(t, ok)
+}
+
+func Test_RawTokenFromRequest(t *testing.T) {
+	testCases := []struct {
+		header string
+		want   string
+	}{
+		{"Bearer token", "token"},
+		{"bearer  token ", "token"},
+		{"Basic dXNlcjpwYXNz", ""},
+		{"Bearer", ""},
+		{"", ""},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.header, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Header.Set(authorizationHeader, testCase.header)
+
+			assert.Equal(t, testCase.want, rawTokenFromRequest(request))
+		})
+	}
+}
+
+func Test_GivenValidRequest_WhenMiddlewaresAreCalled_ThenExpectTheRawTokenInTheContext(t *testing.T) {
+	// Given
+	validator := NewValidator(
+		config.NewAudienceConfig(defaultAudience[0]),
+		WithIssuer(defaultIssuer),
+		withSecretProvider(defaultSecretProvider),
+	)
+	request := newTestTokenConfig().newRequest()
+	expectedToken := rawTokenFromRequest(request)
+
+	var rawTokens []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		rawToken, _ := RawTokenFromContext(r.Context())
+		rawTokens = append(rawTokens, rawToken)
+	}
+
+	// When
+	validator.Middleware(http.HandlerFunc(handler)).ServeHTTP(httptest.NewRecorder(), request)
+	validator.HandlerFunc(handler)(httptest.NewRecorder(), request)
+
+	c := echo.New().NewContext(request, httptest.NewRecorder())
+	err := validator.EchoMiddlewareFunc()(func(c echo.Context) error {
+		handler(nil, c.Request())
+		return nil
+	})(c)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, []string{expectedToken, expectedToken, expectedToken}, rawTokens)
+}
+
+func Test_GivenValidRequest_WhenMiddlewaresAreCalled_ThenExpectTheValidatedTokenInTheContext(t *testing.T) {
+	// Given
+	validator := NewValidator(
+		config.NewAudienceConfig(defaultAudience[0]),
+		WithIssuer(defaultIssuer),
+		withSecretProvider(defaultSecretProvider),
+	)
+	request := newTestTokenConfig().newRequest()
+
+	var issuers []string
+	readIssuer := func(token TokenWithClaims, ok bool) {
+		require.True(t, ok)
+		claims, err := token.StandardClaims()
+		require.NoError(t, err)
+		issuers = append(issuers, claims.Issuer)
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		readIssuer(TokenFromContext(r.Context()))
+	}
+
+	// When
+	validator.Middleware(http.HandlerFunc(handler)).ServeHTTP(httptest.NewRecorder(), request)
+	validator.HandlerFunc(handler)(httptest.NewRecorder(), request)
+
+	c := echo.New().NewContext(request, httptest.NewRecorder())
+	err := validator.EchoMiddlewareFunc()(func(c echo.Context) error {
+		readIssuer(TokenFromEcho(c))
+		readIssuer(TokenFromContext(c.Request().Context()))
+		return nil
+	})(c)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, []string{defaultIssuer, defaultIssuer, defaultIssuer, defaultIssuer}, issuers)
+}
+
+func Test_GivenContextWithoutToken_WhenTokenIsRead_ThenExpectNotFound(t *testing.T) {
+	// Given
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+	// When
+	_, okContext := TokenFromContext(context.Background())
+	_, okEcho := TokenFromEcho(c)
+
+	// Then
+	assert.False(t, okContext)
+	assert.False(t, okEcho)
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/bitrise-io/go-auth0"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// The kinds of the validation errors. The errors of the validators and the middlewares are *ValidationError
+// values, which match their kind with errors.Is, as well as the underlying go-auth0 or go-jose error.
+var (
+	// ErrMissingToken is returned when the request has no bearer token.
+	ErrMissingToken = errors.New("no token found in the request")
+	// ErrMalformedToken is returned when the token can not be parsed, or it misses a required claim.
+	ErrMalformedToken = errors.New("malformed token")
+	// ErrTokenExpired is returned when the token expired, or it is older than the maximum token age.
+	ErrTokenExpired = errors.New("token expired")
+	// ErrTokenNotYetValid is returned when the token is used before its nbf or iat.
+	ErrTokenNotYetValid = errors.New("token is not valid yet")
+	// ErrInvalidSignature is returned when the signature is invalid, or the algorithm is not accepted.
+	ErrInvalidSignature = errors.New("invalid token signature")
+	// ErrUnknownKey is returned when the key of the token is not in the key set.
+	ErrUnknownKey = errors.New("unknown signing key")
+	// ErrIssuerMismatch is returned when the issuer of the token is not the expected or an allowed one.
+	ErrIssuerMismatch = errors.New("issuer mismatch")
+	// ErrAudienceMismatch is returned when the token is not issued for the audience of the service.
+	ErrAudienceMismatch = errors.New("audience mismatch")
+	// ErrJWKSUnavailable is returned when the keys of the issuer can not be fetched or read.
+	ErrJWKSUnavailable = errors.New("JWKS is unavailable")
+	// ErrTokenRevoked is returned when the RevocationChecker of the validator reports the token as revoked.
+	ErrTokenRevoked = errors.New("token revoked")
+	// ErrRevocationCheckFailed is returned when the RevocationChecker fails, e.g. its store is unavailable.
+	// The token is rejected, as it can't be proven not to be revoked, but it is not reported as revoked.
+	ErrRevocationCheckFailed = errors.New("revocation check failed")
+)
+
+// ValidationError is the error of a failed validation, Kind is one of the error kinds (like ErrTokenExpired
+// or ErrInsufficientScope) and Err is the underlying error.
+type ValidationError struct {
+	Kind error
+	Err  error
+}
+
+// Error ...
+func (e *ValidationError) Error() string {
+	if e.Err == nil {
+		return e.Kind.Error()
+	}
+	if errors.Is(e.Err, e.Kind) {
+		return e.Err.Error()
+	}
+
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap returns both the kind and the underlying error, so errors.Is matches any of them.
+func (e *ValidationError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+
+	return []error{e.Kind, e.Err}
+}
+
+// errorReasons are the reasons of the error kinds, see ErrorReason.
+var errorReasons = map[error]string{
+	ErrMissingToken:      "token_not_found",
+	ErrMalformedToken:    "malformed_token",
+	ErrTokenExpired:      "expired",
+	ErrTokenNotYetValid:  "not_valid_yet",
+	ErrInvalidSignature:  "invalid_signature",
+	ErrUnknownKey:        "unknown_key",
+	ErrIssuerMismatch:    "invalid_issuer",
+	ErrAudienceMismatch:  "invalid_audience",
+	ErrInsufficientScope: "insufficient_scope",
+	ErrJWKSUnavailable:   "jwks_unavailable",
+	ErrTokenRevoked:      "revoked",
+
+	ErrRevocationCheckFailed: "revocation_check_failed",
+}
+
+// ErrorReason returns a low cardinality reason of the validation error, that does not contain the token,
+// it is used as the error type of the traces and the reason tag of the metrics.
+func ErrorReason(err error) string {
+	switch {
+	case errors.Is(err, auth0.ErrInvalidAlgorithm):
+		return "invalid_algorithm"
+	case errors.Is(err, ErrTokenTooOld):
+		return "too_old"
+	case errors.Is(err, ErrMissingExpiration), errors.Is(err, ErrMissingIssuedAt):
+		return "missing_claim"
+	default:
+		return errorReasons[validationErrorKind(err)]
+	}
+}
+
+// newValidationError classifies the error into a *ValidationError, it returns nil for nil.
+func newValidationError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return err
+	}
+
+	return &ValidationError{Kind: validationErrorKind(err), Err: err}
+}
+
+// validationErrorKind returns the kind of the error, the errors that are not recognized are malformed tokens.
+func validationErrorKind(err error) error {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Kind
+	}
+
+	var urlErr *url.Error
+
+	switch {
+	case errors.Is(err, ErrInsufficientScope):
+		return ErrInsufficientScope
+	case errors.Is(err, ErrRevocationCheckFailed):
+		return ErrRevocationCheckFailed
+	case errors.Is(err, ErrTokenRevoked):
+		return ErrTokenRevoked
+	case errors.Is(err, ErrMissingToken), errors.Is(err, auth0.ErrTokenNotFound):
+		return ErrMissingToken
+	case errors.Is(err, ErrJWKSUnavailable), errors.Is(err, ErrIssuerDiscoveryFailed),
+		errors.Is(err, auth0.ErrInvalidContentType), errors.As(err, &urlErr):
+		return ErrJWKSUnavailable
+	case errors.Is(err, ErrUnknownKey), errors.Is(err, auth0.ErrNoKeyFound), errors.Is(err, auth0.ErrKeyExpired):
+		return ErrUnknownKey
+	case errors.Is(err, ErrInvalidSignature), errors.Is(err, auth0.ErrInvalidAlgorithm), errors.Is(err, jose.ErrCryptoFailure):
+		return ErrInvalidSignature
+	case errors.Is(err, ErrTokenExpired), errors.Is(err, jwt.ErrExpired), errors.Is(err, ErrTokenTooOld):
+		return ErrTokenExpired
+	case errors.Is(err, ErrTokenNotYetValid), errors.Is(err, jwt.ErrNotValidYet), errors.Is(err, jwt.ErrIssuedInTheFuture):
+		return ErrTokenNotYetValid
+	case errors.Is(err, ErrIssuerMismatch), errors.Is(err, jwt.ErrInvalidIssuer),
+		errors.Is(err, ErrUnknownIssuer), errors.Is(err, ErrIssuerNotAllowed):
+		return ErrIssuerMismatch
+	case errors.Is(err, ErrAudienceMismatch), errors.Is(err, jwt.ErrInvalidAudience):
+		return ErrAudienceMismatch
+	default:
+		return ErrMalformedToken
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/bitrise-io/go-auth0"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ValidationErrorKinds(t *testing.T) {
+	testCases := []struct {
+		err            error
+		expectedKind   error
+		expectedReason string
+	}{
+		{auth0.ErrTokenNotFound, ErrMissingToken, "token_not_found"},
+		{fmt.Errorf("%w: %w", ErrMalformedToken, errors.New("compact JWS format must have three parts")), ErrMalformedToken, "malformed_token"},
+		{jwt.ErrExpired, ErrTokenExpired, "expired"},
+		{ErrTokenTooOld, ErrTokenExpired, "too_old"},
+		{jwt.ErrIssuedInTheFuture, ErrTokenNotYetValid, "not_valid_yet"},
+		{jose.ErrCryptoFailure, ErrInvalidSignature, "invalid_signature"},
+		{auth0.ErrInvalidAlgorithm, ErrInvalidSignature, "invalid_algorithm"},
+		{fmt.Errorf("%w: kid %q", auth0.ErrNoKeyFound, "kid"), ErrUnknownKey, "unknown_key"},
+		{fmt.Errorf("%w: %s", ErrUnknownIssuer, "issuer"), ErrIssuerMismatch, "invalid_issuer"},
+		{jwt.ErrInvalidAudience, ErrAudienceMismatch, "invalid_audience"},
+		{fmt.Errorf("%w: scope app:write is missing", ErrInsufficientScope), ErrInsufficientScope, "insufficient_scope"},
+		{&url.Error{Op: "Get", URL: "https://auth.example.com/certs", Err: errors.New("timeout")}, ErrJWKSUnavailable, "jwks_unavailable"},
+		{fmt.Errorf("%w: %w: kid %q", ErrJWKSUnavailable, auth0.ErrNoKeyFound, "kid"), ErrJWKSUnavailable, "jwks_unavailable"},
+		{ErrTokenRevoked, ErrTokenRevoked, "revoked"},
+		{fmt.Errorf("%w: %w", ErrRevocationCheckFailed, errors.New("connection refused")), ErrRevocationCheckFailed, "revocation_check_failed"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.err.Error(), func(t *testing.T) {
+			// When
+			err := newValidationError(testCase.err)
+
+			// Then
+			var validationErr *ValidationError
+			require.True(t, errors.As(err, &validationErr))
+			assert.Equal(t, testCase.expectedKind, validationErr.Kind)
+			assert.ErrorIs(t, err, testCase.expectedKind)
+			assert.ErrorIs(t, err, testCase.err)
+			assert.Equal(t, testCase.expectedReason, ErrorReason(err))
+		})
+	}
+}
+
+func Test_GivenValidationError_WhenItIsClassifiedAgain_ThenExpectItToBeKept(t *testing.T) {
+	// Given
+	err := newValidationError(fmt.Errorf("validation failed: %w", jwt.ErrExpired))
+
+	// When
+	wrapped := newValidationError(fmt.Errorf("middleware: %w", err))
+
+	// Then
+	assert.Equal(t, "token expired: validation failed: go-jose/go-jose/jwt: validation failed, token is expired (exp)", err.Error())
+	assert.ErrorIs(t, wrapped, ErrTokenExpired)
+	assert.Equal(t, ErrTokenExpired, validationErrorKind(wrapped))
+	assert.NoError(t, newValidationError(nil))
+}
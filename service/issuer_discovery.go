@@ -0,0 +1,267 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	// maxDiscoveryDocumentSize limits the response read from the discovery endpoint.
+	maxDiscoveryDocumentSize = 1 << 20
+
+	defaultMaxConcurrentDiscoveries = 4
+	defaultMaxDiscoveriesPerMinute  = 60
+	defaultMaxCachedFailures        = 1024
+)
+
+var (
+	// ErrIssuerDiscoveryFailed is returned (wrapped into ErrUnknownIssuer) when the validator of an allowed issuer
+	// cannot be created from its OIDC discovery document.
+	ErrIssuerDiscoveryFailed = errors.New("issuer discovery failed")
+	// ErrIssuerDiscoveryLimited is returned (wrapped into ErrIssuerDiscoveryFailed) when the issuer is not discovered
+	// because too many discoveries are running or were started in the last minute, see WithDiscoveryLimits.
+	ErrIssuerDiscoveryLimited = errors.New("too many issuer discoveries")
+)
+
+// discoveryLimits bound the discoveries of all the issuers, because the discovered issuers come from
+// unverified tokens: every new issuer matching the allowlist costs an outbound request.
+type discoveryLimits struct {
+	maxConcurrent     int
+	maxPerMinute      int
+	maxCachedFailures int
+}
+
+func defaultDiscoveryLimits() discoveryLimits {
+	return discoveryLimits{
+		maxConcurrent:     defaultMaxConcurrentDiscoveries,
+		maxPerMinute:      defaultMaxDiscoveriesPerMinute,
+		maxCachedFailures: defaultMaxCachedFailures,
+	}
+}
+
+// issuerDiscovery creates the validators of the allowed but not registered issuers from their discovery document.
+// Concurrent lookups of the same issuer share one request, and the failures are cached for failureTTL,
+// so tokens of a broken or non-existing issuer don't trigger a request each. The number of concurrent
+// discoveries, the rate of the discoveries and the size of the failure cache are limited.
+type issuerDiscovery struct {
+	audience   config.AudienceConfig
+	failureTTL time.Duration
+	limits     discoveryLimits
+	client     *http.Client
+	now        func() time.Time
+
+	mu       sync.Mutex
+	failures map[string]discoveryFailure
+	inflight map[string]*discoveryCall
+	// tokens is the number of discoveries that can be started, it is refilled by maxPerMinute per minute
+	tokens   float64
+	refilled time.Time
+}
+
+type discoveryFailure struct {
+	err   error
+	until time.Time
+}
+
+type discoveryCall struct {
+	done      chan struct{}
+	validator Validator
+	err       error
+}
+
+type discoveryDocument struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+func newIssuerDiscovery(audience config.AudienceConfig, failureTTL time.Duration) *issuerDiscovery {
+	return &issuerDiscovery{
+		audience:   audience,
+		failureTTL: failureTTL,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			// redirects are not followed, they could point to any host
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		limits:   defaultDiscoveryLimits(),
+		now:      time.Now,
+		failures: map[string]discoveryFailure{},
+		inflight: map[string]*discoveryCall{},
+		tokens:   defaultMaxDiscoveriesPerMinute,
+	}
+}
+
+// setLimits replaces the limits, the discoveries of the last minute are forgotten.
+func (d *issuerDiscovery) setLimits(limits discoveryLimits) {
+	d.limits = limits
+	d.tokens = float64(limits.maxPerMinute)
+	d.refilled = time.Time{}
+}
+
+// allowDiscovery reports whether a new discovery can be started, it takes one of the tokens if so.
+// d.mu must be held.
+func (d *issuerDiscovery) allowDiscovery() bool {
+	if len(d.inflight) >= d.limits.maxConcurrent {
+		return false
+	}
+
+	now := d.now()
+	if !d.refilled.IsZero() {
+		d.tokens += now.Sub(d.refilled).Minutes() * float64(d.limits.maxPerMinute)
+		if d.tokens > float64(d.limits.maxPerMinute) {
+			d.tokens = float64(d.limits.maxPerMinute)
+		}
+	}
+	d.refilled = now
+
+	if d.tokens < 1 {
+		return false
+	}
+	d.tokens--
+
+	return true
+}
+
+// discoverValidator returns the validator of an issuer that is not registered yet. Only allowed HTTPS issuers
+// are contacted, the discovered validator is registered in the repository.
+func (vr *DefaultJwtValidatorRepository) discoverValidator(issuer string) (Validator, error) {
+	if !vr.IssuerAllowed(issuer) {
+		return nil, fmt.Errorf("%w: %s", ErrIssuerNotAllowed, issuer)
+	}
+	if err := validateHTTPSURL(issuer); err != nil {
+		return nil, fmt.Errorf("%w: issuer %s", ErrIssuerDiscoveryFailed, err)
+	}
+
+	d := vr.discovery
+	d.mu.Lock()
+	if failure, ok := d.failures[issuer]; ok && d.now().Before(failure.until) {
+		d.mu.Unlock()
+		return nil, failure.err
+	}
+	if call, ok := d.inflight[issuer]; ok {
+		d.mu.Unlock()
+		<-call.done
+		return call.validator, call.err
+	}
+	if !d.allowDiscovery() {
+		d.mu.Unlock()
+		return nil, fmt.Errorf("%w: %w", ErrIssuerDiscoveryFailed, ErrIssuerDiscoveryLimited)
+	}
+	call := &discoveryCall{done: make(chan struct{})}
+	d.inflight[issuer] = call
+	d.mu.Unlock()
+
+	call.validator, call.err = vr.newDiscoveredValidator(issuer)
+	if call.err == nil {
+		vr.mu.Lock()
+		vr.entries[issuer] = issuerEntry{validator: call.validator, discovered: true}
+		vr.mu.Unlock()
+	}
+
+	d.mu.Lock()
+	delete(d.inflight, issuer)
+	if call.err != nil {
+		d.addFailure(issuer, call.err)
+	}
+	d.mu.Unlock()
+	close(call.done)
+
+	return call.validator, call.err
+}
+
+func (vr *DefaultJwtValidatorRepository) newDiscoveredValidator(issuer string) (Validator, error) {
+	document, err := vr.discovery.fetchDocument(issuer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrIssuerDiscoveryFailed, err)
+	}
+
+	validatorOpts := []ValidatorOption{WithIssuer(issuer), WithJWKSUrl(document.JWKSURI)}
+	validatorOpts = append(validatorOpts, vr.validatorOptions...)
+
+	return NewValidator(vr.discovery.audience, validatorOpts...), nil
+}
+
+// fetchDocument downloads the discovery document of the issuer. The document must belong to the same issuer,
+// and its JWKS endpoint must be an HTTPS URL on the host of the issuer, so a document can't make the
+// service fetch keys from elsewhere.
+func (d *issuerDiscovery) fetchDocument(issuer string) (discoveryDocument, error) {
+	resp, err := d.client.Get(strings.TrimSuffix(issuer, "/") + discoveryPath)
+	if err != nil {
+		return discoveryDocument{}, fmt.Errorf("failed to fetch the discovery document: %w", err)
+	}
+	defer resp.Body.Close() //nolint: errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return discoveryDocument{}, fmt.Errorf("discovery endpoint responded with status %d", resp.StatusCode)
+	}
+
+	var document discoveryDocument
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDiscoveryDocumentSize)).Decode(&document); err != nil {
+		return discoveryDocument{}, fmt.Errorf("failed to decode the discovery document: %w", err)
+	}
+
+	if document.Issuer != issuer {
+		return discoveryDocument{}, fmt.Errorf("discovery document belongs to issuer %q", document.Issuer)
+	}
+
+	if err := validateHTTPSURL(document.JWKSURI); err != nil {
+		return discoveryDocument{}, fmt.Errorf("jwks_uri %s", err)
+	}
+
+	issuerURL, _ := url.Parse(issuer)
+	jwksURL, _ := url.Parse(document.JWKSURI)
+	if jwksURL.Host != issuerURL.Host {
+		return discoveryDocument{}, fmt.Errorf("jwks_uri %q is not on the host of the issuer", document.JWKSURI)
+	}
+
+	return document, nil
+}
+
+// addFailure caches the failure of the issuer, the expired failures are dropped to keep the cache small.
+// If the cache is still full, the failure that expires first is dropped.
+func (d *issuerDiscovery) addFailure(issuer string, err error) {
+	if d.failureTTL <= 0 || d.limits.maxCachedFailures <= 0 {
+		return
+	}
+
+	now := d.now()
+	var oldest string
+	for iss, failure := range d.failures {
+		if !now.Before(failure.until) {
+			delete(d.failures, iss)
+		} else if oldest == "" || failure.until.Before(d.failures[oldest].until) {
+			oldest = iss
+		}
+	}
+
+	if _, ok := d.failures[issuer]; !ok && len(d.failures) >= d.limits.maxCachedFailures {
+		delete(d.failures, oldest)
+	}
+
+	d.failures[issuer] = discoveryFailure{err: err, until: now.Add(d.failureTTL)}
+}
+
+func validateHTTPSURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid URL", value)
+	}
+
+	if u.Scheme != "https" || u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("%q is not a plain HTTPS URL", value)
+	}
+
+	return nil
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type discoveryServer struct {
+	*httptest.Server
+	requests atomic.Int32
+	// documents returns the status and body of the discovery endpoint of the realm
+	documents func(server *discoveryServer, realm string) (int, string)
+}
+
+func newDiscoveryServer(t *testing.T, documents func(server *discoveryServer, realm string) (int, string)) *discoveryServer {
+	server := &discoveryServer{documents: documents}
+	server.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.requests.Add(1)
+
+		var realm string
+		if _, err := fmt.Sscanf(r.URL.Path, "/auth/realms/%s", &realm); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		status, body := server.documents(server, realm[:len(realm)-len(discoveryPath)])
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func (s *discoveryServer) issuer(realm string) string {
+	return s.URL + "/auth/realms/" + realm
+}
+
+func validDiscoveryDocument(server *discoveryServer, realm string) (int, string) {
+	return http.StatusOK, fmt.Sprintf(`{"issuer": %q, "jwks_uri": %q}`,
+		server.issuer(realm), issuerJWKSURL(server.issuer(realm)))
+}
+
+func givenDiscoveryRegistry(t *testing.T, server *discoveryServer, opts ...JwtValidatorRepositoryOption) *DefaultJwtValidatorRepository {
+	vr, err := NewJwtValidatorRegistry(append([]JwtValidatorRepositoryOption{
+		WithAllowedIssuers(server.URL+"/auth/realms/*", "http://*/auth/realms/*"),
+		WithIssuerDiscovery(config.NewAudienceConfig("bitrise-api"), time.Minute),
+		WithDiscoveryHTTPClient(server.Client()),
+	}, opts...)...)
+	require.NoError(t, err)
+
+	return vr
+}
+
+func Test_GivenAllowedIssuer_WhenItIsNotRegistered_ThenExpectTheValidatorToBeDiscovered(t *testing.T) {
+	// Given
+	server := newDiscoveryServer(t, validDiscoveryDocument)
+	vr := givenDiscoveryRegistry(t, server)
+	issuer := server.issuer("new-realm")
+	rawToken := newTestTokenConfigWithIssuer(issuer).getTokenString()
+
+	// When
+	v, iss, err := vr.GetJwtValidatorForRawToken(rawToken)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, issuer, iss)
+	validatorConfig := v.(*ValidatorConfig)
+	assert.Equal(t, issuer, validatorConfig.issuer)
+	assert.Equal(t, issuer+"/protocol/openid-connect/certs", validatorConfig.jwksURL)
+	assert.Equal(t, []string{"bitrise-api"}, validatorConfig.audience.All())
+	assert.Equal(t, []string{issuer}, vr.Issuers())
+
+	// When
+	cached, _, err := vr.GetJwtValidatorForRawToken(rawToken)
+
+	// Then
+	require.NoError(t, err)
+	assert.Same(t, v, cached)
+	assert.Equal(t, int32(1), server.requests.Load())
+}
+
+func Test_GivenConcurrentRequestsOfTheSameIssuer_WhenItIsDiscovered_ThenExpectOneRequest(t *testing.T) {
+	// Given
+	server := newDiscoveryServer(t, func(server *discoveryServer, realm string) (int, string) {
+		time.Sleep(50 * time.Millisecond)
+		return validDiscoveryDocument(server, realm)
+	})
+	vr := givenDiscoveryRegistry(t, server)
+	rawToken := newTestTokenConfigWithIssuer(server.issuer("new-realm")).getTokenString()
+
+	// When
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := vr.GetJwtValidatorForRawToken(rawToken)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	// Then
+	assert.Equal(t, int32(1), server.requests.Load())
+}
+
+func Test_GivenFailingDiscovery_WhenTheIssuerIsLookedUpAgain_ThenExpectTheFailureToBeCached(t *testing.T) {
+	// Given
+	server := newDiscoveryServer(t, func(*discoveryServer, string) (int, string) {
+		return http.StatusNotFound, ""
+	})
+	vr := givenDiscoveryRegistry(t, server)
+	now := time.Now()
+	vr.discovery.now = func() time.Time { return now }
+	rawToken := newTestTokenConfigWithIssuer(server.issuer("missing-realm")).getTokenString()
+
+	// When
+	_, _, err := vr.GetJwtValidatorForRawToken(rawToken)
+	_, _, cachedErr := vr.GetJwtValidatorForRawToken(rawToken)
+
+	// Then
+	assert.ErrorIs(t, err, ErrUnknownIssuer)
+	assert.ErrorIs(t, err, ErrIssuerDiscoveryFailed)
+	assert.ErrorContains(t, err, "discovery endpoint responded with status 404")
+	assert.Equal(t, err, cachedErr)
+	assert.Equal(t, int32(1), server.requests.Load())
+
+	// When
+	now = now.Add(2 * time.Minute)
+	_, _, err = vr.GetJwtValidatorForRawToken(rawToken)
+
+	// Then
+	assert.ErrorIs(t, err, ErrIssuerDiscoveryFailed)
+	assert.Equal(t, int32(2), server.requests.Load())
+}
+
+func Test_GivenDiscoveryRateLimit_WhenNewIssuersAreLookedUp_ThenExpectTheDiscoveriesOverTheLimitToFail(t *testing.T) {
+	// Given
+	server := newDiscoveryServer(t, validDiscoveryDocument)
+	vr := givenDiscoveryRegistry(t, server, WithDiscoveryLimits(4, 2, 1024))
+	now := time.Now()
+	vr.discovery.now = func() time.Time { return now }
+
+	// When
+	var errs []error
+	for _, realm := range []string{"realm-1", "realm-2", "realm-3"} {
+		_, _, err := vr.GetJwtValidatorForRawToken(newTestTokenConfigWithIssuer(server.issuer(realm)).getTokenString())
+		errs = append(errs, err)
+	}
+
+	// Then
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.ErrorIs(t, errs[2], ErrUnknownIssuer)
+	assert.ErrorIs(t, errs[2], ErrIssuerDiscoveryLimited)
+	assert.Equal(t, int32(2), server.requests.Load())
+
+	// When
+	now = now.Add(30 * time.Second)
+	_, _, err := vr.GetJwtValidatorForRawToken(newTestTokenConfigWithIssuer(server.issuer("realm-3")).getTokenString())
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), server.requests.Load())
+}
+
+func Test_GivenDiscoveryConcurrencyLimit_WhenIssuersAreDiscoveredConcurrently_ThenExpectTheDiscoveriesOverTheLimitToFail(t *testing.T) {
+	// Given
+	release := make(chan struct{})
+	server := newDiscoveryServer(t, func(server *discoveryServer, realm string) (int, string) {
+		<-release
+		return validDiscoveryDocument(server, realm)
+	})
+	vr := givenDiscoveryRegistry(t, server, WithDiscoveryLimits(1, 60, 1024))
+
+	done := make(chan error)
+	go func() {
+		_, _, err := vr.GetJwtValidatorForRawToken(newTestTokenConfigWithIssuer(server.issuer("slow-realm")).getTokenString())
+		done <- err
+	}()
+	require.Eventually(t, func() bool { return server.requests.Load() == 1 }, time.Second, time.Millisecond)
+
+	// When
+	_, _, err := vr.GetJwtValidatorForRawToken(newTestTokenConfigWithIssuer(server.issuer("other-realm")).getTokenString())
+	close(release)
+
+	// Then
+	assert.ErrorIs(t, err, ErrIssuerDiscoveryLimited)
+	assert.NoError(t, <-done)
+	assert.Equal(t, int32(1), server.requests.Load())
+}
+
+func Test_GivenFailureCacheLimit_WhenMoreIssuersFail_ThenExpectTheCacheToBeBounded(t *testing.T) {
+	// Given
+	server := newDiscoveryServer(t, func(*discoveryServer, string) (int, string) {
+		return http.StatusNotFound, ""
+	})
+	vr := givenDiscoveryRegistry(t, server, WithDiscoveryLimits(4, 60, 2))
+	now := time.Now()
+	vr.discovery.now = func() time.Time { return now }
+
+	// When
+	for _, realm := range []string{"realm-1", "realm-2", "realm-3"} {
+		now = now.Add(time.Second)
+		_, _, err := vr.GetJwtValidatorForRawToken(newTestTokenConfigWithIssuer(server.issuer(realm)).getTokenString())
+		require.ErrorIs(t, err, ErrIssuerDiscoveryFailed)
+	}
+
+	// Then
+	require.Len(t, vr.discovery.failures, 2)
+	assert.NotContains(t, vr.discovery.failures, server.issuer("realm-1"))
+	assert.Contains(t, vr.discovery.failures, server.issuer("realm-3"))
+}
+
+func Test_GivenUntrustedIssuerOrDocument_WhenTheIssuerIsLookedUp_ThenExpectError(t *testing.T) {
+	server := newDiscoveryServer(t, func(server *discoveryServer, realm string) (int, string) {
+		switch realm {
+		case "other-issuer":
+			return http.StatusOK, fmt.Sprintf(`{"issuer": %q, "jwks_uri": %q}`,
+				server.issuer("master"), issuerJWKSURL(server.issuer("master")))
+		case "other-host":
+			return http.StatusOK, fmt.Sprintf(`{"issuer": %q, "jwks_uri": "https://attacker.example.com/certs"}`,
+				server.issuer(realm))
+		case "plain-http":
+			return http.StatusOK, fmt.Sprintf(`{"issuer": %q, "jwks_uri": %q}`,
+				server.issuer(realm), "http"+issuerJWKSURL(server.issuer(realm))[len("https"):])
+		default:
+			return validDiscoveryDocument(server, realm)
+		}
+	})
+
+	tests := []struct {
+		name          string
+		issuer        string
+		wantErr       error
+		wantErrString string
+		wantRequest   bool
+	}{
+		{
+			name:        "issuer outside of the allowlist",
+			issuer:      server.URL + "/other/realm",
+			wantErr:     ErrIssuerNotAllowed,
+			wantRequest: false,
+		},
+		{
+			name:          "plain HTTP issuer",
+			issuer:        "http" + server.issuer("realm")[len("https"):],
+			wantErr:       ErrIssuerDiscoveryFailed,
+			wantErrString: "is not a plain HTTPS URL",
+			wantRequest:   false,
+		},
+		{
+			name:          "document of an other issuer",
+			issuer:        server.issuer("other-issuer"),
+			wantErr:       ErrIssuerDiscoveryFailed,
+			wantErrString: "discovery document belongs to issuer",
+			wantRequest:   true,
+		},
+		{
+			name:          "JWKS on an other host",
+			issuer:        server.issuer("other-host"),
+			wantErr:       ErrIssuerDiscoveryFailed,
+			wantErrString: "is not on the host of the issuer",
+			wantRequest:   true,
+		},
+		{
+			name:          "plain HTTP JWKS",
+			issuer:        server.issuer("plain-http"),
+			wantErr:       ErrIssuerDiscoveryFailed,
+			wantErrString: "is not a plain HTTPS URL",
+			wantRequest:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			vr := givenDiscoveryRegistry(t, server)
+			requestsBefore := server.requests.Load()
+
+			// When
+			_, _, err := vr.GetJwtValidatorForRawToken(newTestTokenConfigWithIssuer(tt.issuer).getTokenString())
+
+			// Then
+			assert.ErrorIs(t, err, ErrUnknownIssuer)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.ErrorContains(t, err, tt.wantErrString)
+			assert.Equal(t, tt.wantRequest, server.requests.Load() > requestsBefore)
+			assert.Empty(t, vr.Issuers())
+		})
+	}
+}
+
+func Test_GivenIssuerDiscoveryWithoutAllowlist_WhenRegistryIsCreated_ThenExpectError(t *testing.T) {
+	// When
+	_, err := NewJwtValidatorRegistry(WithIssuerDiscovery(config.NewAudienceConfig("bitrise-api"), time.Minute))
+
+	// Then
+	assert.EqualError(t, err, "issuer discovery requires an allowlist (WithAllowedIssuers)")
+}
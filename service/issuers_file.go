@@ -0,0 +1,120 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+)
+
+// issuerJWKSURL returns the Keycloak JWKS endpoint of the issuer.
+func issuerJWKSURL(issuer string) string {
+	return fmt.Sprintf("%s/protocol/openid-connect/certs", strings.TrimSuffix(issuer, "/"))
+}
+
+// reloadIssuersFile loads the issuers file and replaces the issuers registered from it.
+// The issuers registered with Register or RegisterIssuer are left untouched, and the validators of the unchanged
+// entries are kept, so their cached keys are not lost. It returns the modification time of the loaded file.
+func (vr *DefaultJwtValidatorRepository) reloadIssuersFile() (time.Time, error) {
+	info, err := os.Stat(vr.issuersFile)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read the issuers file: %w", err)
+	}
+
+	issuersConfig, err := config.LoadIssuersConfigFromFile(vr.issuersFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := issuersConfig.Validate(); err != nil {
+		return time.Time{}, err
+	}
+
+	for _, issuerConfig := range issuersConfig.Issuers {
+		if !vr.IssuerAllowed(issuerConfig.Issuer) {
+			return time.Time{}, fmt.Errorf("%w: %s", ErrIssuerNotAllowed, issuerConfig.Issuer)
+		}
+	}
+
+	vr.mu.RLock()
+	current := make(map[string]issuerEntry, len(vr.entries))
+	for iss, entry := range vr.entries {
+		current[iss] = entry
+	}
+	vr.mu.RUnlock()
+
+	// the validators are created before taking the lock, so a broken entry leaves the previous issuers in place
+	loaded := map[string]issuerEntry{}
+	for _, issuerConfig := range issuersConfig.Issuers {
+		if entry, ok := current[issuerConfig.Issuer]; ok && entry.fromFile && reflect.DeepEqual(entry.config, issuerConfig) {
+			loaded[issuerConfig.Issuer] = entry
+			continue
+		}
+
+		validator, err := vr.newValidatorFromIssuerConfig(issuerConfig)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("issuer %s: %w", issuerConfig.Issuer, err)
+		}
+		loaded[issuerConfig.Issuer] = issuerEntry{validator: validator, fromFile: true, config: issuerConfig}
+	}
+
+	vr.mu.Lock()
+	defer vr.mu.Unlock()
+
+	for iss, entry := range vr.entries {
+		if entry.fromFile {
+			delete(vr.entries, iss)
+		}
+	}
+	for iss, entry := range loaded {
+		if existing, ok := vr.entries[iss]; ok && !existing.fromFile && !existing.discovered {
+			continue
+		}
+		vr.entries[iss] = entry
+	}
+
+	return info.ModTime(), nil
+}
+
+// newValidatorFromIssuerConfig creates the validator of a file entry, the keys are fetched from the JWKS endpoint
+// of the issuer unless the entry sets jwks_url.
+func (vr *DefaultJwtValidatorRepository) newValidatorFromIssuerConfig(issuerConfig config.ValidatorConfig) (Validator, error) {
+	if issuerConfig.JWKSURL == "" {
+		issuerConfig.JWKSURL = issuerJWKSURL(issuerConfig.Issuer)
+	}
+
+	return NewValidatorFromConfig(issuerConfig, vr.validatorOptions...)
+}
+
+// watchIssuersFile polls the modification time of the issuers file and reloads it when it changes.
+func (vr *DefaultJwtValidatorRepository) watchIssuersFile(modTime time.Time) {
+	ticker := time.NewTicker(vr.reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-vr.stop:
+			return
+		case <-ticker.C:
+			info, err := os.Stat(vr.issuersFile)
+			if err != nil {
+				vr.reloadErrorHandler(fmt.Errorf("failed to read the issuers file: %w", err))
+				continue
+			}
+			if info.ModTime().Equal(modTime) {
+				continue
+			}
+
+			newModTime, err := vr.reloadIssuersFile()
+			if err != nil {
+				vr.reloadErrorHandler(err)
+				// the broken version is not retried until the file changes again
+				modTime = info.ModTime()
+				continue
+			}
+			modTime = newModTime
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/go-auth0"
+	"github.com/go-jose/go-jose/v4"
+)
+
+const (
+	defaultJWKSRefreshInterval    = 15 * time.Minute
+	defaultJWKSMinRefetchInterval = 30 * time.Second
+	// maxJWKSSize limits the response read from the JWKS endpoint.
+	maxJWKSSize = 1 << 20
+)
+
+// JWKSManagerOption ...
+type JWKSManagerOption func(m *JWKSManager)
+
+// WithJWKSRefreshInterval sets how often the key set is refreshed in the background, 15 minutes by default.
+func WithJWKSRefreshInterval(interval time.Duration) JWKSManagerOption {
+	return func(m *JWKSManager) {
+		m.refreshInterval = interval
+	}
+}
+
+// WithJWKSMinRefetchInterval sets the minimum time between two fetches triggered by tokens with an unknown key ID,
+// 30 seconds by default, so tokens with random key IDs can't flood the JWKS endpoint.
+func WithJWKSMinRefetchInterval(interval time.Duration) JWKSManagerOption {
+	return func(m *JWKSManager) {
+		m.minRefetchInterval = interval
+	}
+}
+
+// WithJWKSHTTPClient sets the HTTP client used to fetch the key set.
+func WithJWKSHTTPClient(client *http.Client) JWKSManagerOption {
+	return func(m *JWKSManager) {
+		m.client = client
+	}
+}
+
+// JWKSManager keeps the key set of a JWKS endpoint in memory, it can be used as the secret provider of a Validator
+// with WithJWKSManager. The key set is fetched when the manager is created and refreshed in the background.
+// A token with an unknown key ID triggers an immediate (rate-limited) refetch, so rotated keys are picked up
+// on their first use. If the endpoint is unreachable, the last fetched keys are used.
+type JWKSManager struct {
+	ctx                context.Context
+	cancel             context.CancelFunc
+	jwksURL            string
+	client             *http.Client
+	refreshInterval    time.Duration
+	minRefetchInterval time.Duration
+	now                func() time.Time
+
+	mu          sync.RWMutex
+	keySet      jose.JSONWebKeySet
+	refreshedAt time.Time
+	lastErr     error
+
+	// fetchMu serializes the fetches, lastFetch is the time of the last attempt
+	fetchMu   sync.Mutex
+	lastFetch time.Time
+}
+
+// NewJWKSManager fetches the key set from the JWKS URL and starts refreshing it in the background,
+// until the context is canceled or the manager is closed. It returns an error if the initial fetch fails.
+func NewJWKSManager(ctx context.Context, jwksURL string, opts ...JWKSManagerOption) (*JWKSManager, error) {
+	m := &JWKSManager{
+		jwksURL:            jwksURL,
+		client:             &http.Client{Timeout: 30 * time.Second},
+		refreshInterval:    defaultJWKSRefreshInterval,
+		minRefetchInterval: defaultJWKSMinRefetchInterval,
+		now:                time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.ctx, m.cancel = context.WithCancel(ctx)
+
+	if err := m.refresh(); err != nil {
+		m.cancel()
+		return nil, err
+	}
+
+	if m.refreshInterval > 0 {
+		go m.refreshPeriodically()
+	}
+
+	return m, nil
+}
+
+// GetSecret returns the key of the token in the request, it implements auth0.SecretProvider.
+func (m *JWKSManager) GetSecret(r *http.Request) (interface{}, error) {
+	kid, err := requestKeyID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Key(kid)
+}
+
+// Key returns the key with the key ID, the key set is refetched if it does not contain the key,
+// unless it was fetched in the last minimum refetch interval.
+func (m *JWKSManager) Key(kid string) (jose.JSONWebKey, error) {
+	if key, ok := lookupKey(m.currentKeySet(), kid); ok {
+		return key, nil
+	}
+
+	m.fetchMu.Lock()
+	// an other request might have fetched the key while this one was waiting
+	if key, ok := lookupKey(m.currentKeySet(), kid); ok {
+		m.fetchMu.Unlock()
+		return key, nil
+	}
+	if m.now().Sub(m.lastFetch) >= m.minRefetchInterval {
+		_ = m.refreshLocked()
+	}
+	m.fetchMu.Unlock()
+
+	if key, ok := lookupKey(m.currentKeySet(), kid); ok {
+		return key, nil
+	}
+
+	// the key might be in the current key set of the issuer
+	if err := m.LastError(); err != nil {
+		return jose.JSONWebKey{}, fmt.Errorf("%w: %w: kid %q: %w", ErrJWKSUnavailable, auth0.ErrNoKeyFound, kid, err)
+	}
+
+	return jose.JSONWebKey{}, fmt.Errorf("%w: kid %q", auth0.ErrNoKeyFound, kid)
+}
+
+// KeySet returns a copy of the current key set, for diagnostics.
+func (m *JWKSManager) KeySet() jose.JSONWebKeySet {
+	return jose.JSONWebKeySet{Keys: append([]jose.JSONWebKey(nil), m.currentKeySet().Keys...)}
+}
+
+func (m *JWKSManager) currentKeySet() jose.JSONWebKeySet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.keySet
+}
+
+// RefreshedAt returns the time of the last successful fetch.
+func (m *JWKSManager) RefreshedAt() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.refreshedAt
+}
+
+// LastError returns the error of the last fetch, or nil if it succeeded.
+func (m *JWKSManager) LastError() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.lastErr
+}
+
+// Close stops the background refresh, the fetched keys remain usable.
+func (m *JWKSManager) Close() error {
+	m.cancel()
+	return nil
+}
+
+func (m *JWKSManager) refreshPeriodically() {
+	ticker := time.NewTicker(m.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			_ = m.refresh()
+		}
+	}
+}
+
+func (m *JWKSManager) refresh() error {
+	m.fetchMu.Lock()
+	defer m.fetchMu.Unlock()
+
+	return m.refreshLocked()
+}
+
+// refreshLocked fetches the key set, the previous keys are kept if the fetch fails. fetchMu must be held.
+func (m *JWKSManager) refreshLocked() error {
+	m.lastFetch = m.now()
+	keySet, err := m.fetch()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastErr = err
+	if err != nil {
+		return err
+	}
+
+	m.keySet = keySet
+	m.refreshedAt = m.lastFetch
+
+	return nil
+}
+
+func (m *JWKSManager) fetch() (jose.JSONWebKeySet, error) {
+	req, err := http.NewRequestWithContext(m.ctx, http.MethodGet, m.jwksURL, nil)
+	if err != nil {
+		return jose.JSONWebKeySet{}, fmt.Errorf("failed to create the JWKS request: %w", err)
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return jose.JSONWebKeySet{}, fmt.Errorf("failed to fetch the JWKS: %w", err)
+	}
+	defer resp.Body.Close() //nolint: errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return jose.JSONWebKeySet{}, fmt.Errorf("JWKS endpoint responded with status %d", resp.StatusCode)
+	}
+
+	return decodeKeySet(io.LimitReader(resp.Body, maxJWKSSize))
+}
+
+func decodeKeySet(r io.Reader) (jose.JSONWebKeySet, error) {
+	var keySet jose.JSONWebKeySet
+	if err := json.NewDecoder(r).Decode(&keySet); err != nil {
+		return jose.JSONWebKeySet{}, fmt.Errorf("failed to decode the JWKS: %w", err)
+	}
+
+	if len(keySet.Keys) == 0 {
+		return jose.JSONWebKeySet{}, errors.New("the JWKS does not contain any keys")
+	}
+
+	return keySet, nil
+}
+
+// lookupKey returns the signing key with the key ID, the encryption keys of the set are ignored.
+// A token without key ID can only be verified if the key set contains a single signing key.
+func lookupKey(keySet jose.JSONWebKeySet, kid string) (jose.JSONWebKey, bool) {
+	var found []jose.JSONWebKey
+	for _, key := range keySet.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		if kid == "" || key.KeyID == kid {
+			found = append(found, key)
+		}
+	}
+
+	if len(found) != 1 {
+		return jose.JSONWebKey{}, false
+	}
+
+	return found[0], true
+}
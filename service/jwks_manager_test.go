@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/bitrise-io/go-auth0"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type jwksServer struct {
+	*httptest.Server
+	requests atomic.Int32
+
+	mu      sync.Mutex
+	keys    []jose.JSONWebKey
+	failing bool
+}
+
+func newJWKSServer(t *testing.T, keys ...jose.JSONWebKey) *jwksServer {
+	server := &jwksServer{keys: keys}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.requests.Add(1)
+
+		server.mu.Lock()
+		defer server.mu.Unlock()
+
+		if server.failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: server.keys})
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func (s *jwksServer) setKeys(keys ...jose.JSONWebKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = keys
+}
+
+func (s *jwksServer) setFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failing = failing
+}
+
+func Test_GivenJWKSManager_WhenTokenIsValidated_ThenExpectThePreloadedKeyToBeUsed(t *testing.T) {
+	// Given
+	server := newJWKSServer(t, defaultSecret.Public())
+	manager, err := NewJWKSManager(context.Background(), server.URL)
+	require.NoError(t, err)
+	defer manager.Close() //nolint: errcheck
+
+	validator := NewValidator(config.NewAudienceConfig(defaultAudience[0]), WithIssuer(defaultIssuer), WithJWKSManager(manager))
+
+	// When
+	err = validator.ValidateRequest(newTestTokenConfig().newRequest())
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), server.requests.Load())
+	require.Len(t, manager.KeySet().Keys, 1)
+	assertSameKey(t, defaultSecret, manager.KeySet().Keys[0])
+	assert.NoError(t, manager.LastError())
+	assert.False(t, manager.RefreshedAt().IsZero())
+}
+
+func Test_GivenJWKSManager_WhenKeyIsRotated_ThenExpectTheUnknownKeyIDToTriggerARateLimitedRefetch(t *testing.T) {
+	// Given
+	server := newJWKSServer(t, defaultSecret.Public())
+	manager, err := NewJWKSManager(context.Background(), server.URL, WithJWKSMinRefetchInterval(time.Minute))
+	require.NoError(t, err)
+	defer manager.Close() //nolint: errcheck
+	now := time.Now()
+	manager.now = func() time.Time { return now }
+
+	rotatedKey := genRSASSAJWK(jose.RS256, "rotated-kid")
+	server.setKeys(defaultSecret.Public(), rotatedKey.Public())
+
+	// When
+	now = now.Add(2 * time.Minute)
+	key, err := manager.Key("rotated-kid")
+
+	// Then
+	require.NoError(t, err)
+	assertSameKey(t, rotatedKey, key)
+	assert.Equal(t, int32(2), server.requests.Load())
+
+	// When
+	_, err = manager.Key("unknown-kid")
+
+	// Then
+	assert.ErrorIs(t, err, auth0.ErrNoKeyFound)
+	assert.Equal(t, int32(2), server.requests.Load())
+}
+
+func Test_GivenUnreachableJWKSEndpoint_WhenKeySetIsRefreshed_ThenExpectTheStaleKeysToBeServed(t *testing.T) {
+	// Given
+	server := newJWKSServer(t, defaultSecret.Public())
+	manager, err := NewJWKSManager(context.Background(), server.URL,
+		WithJWKSRefreshInterval(10*time.Millisecond),
+		WithJWKSMinRefetchInterval(0))
+	require.NoError(t, err)
+	defer manager.Close() //nolint: errcheck
+
+	// When
+	server.setFailing(true)
+
+	// Then
+	assert.Eventually(t, func() bool { return manager.LastError() != nil }, time.Second, 10*time.Millisecond)
+	assert.EqualError(t, manager.LastError(), "JWKS endpoint responded with status 503")
+	key, err := manager.Key(defaultKid)
+	require.NoError(t, err)
+	assertSameKey(t, defaultSecret, key)
+
+	_, err = manager.Key("rotated-kid")
+	assert.ErrorIs(t, err, ErrJWKSUnavailable)
+	assert.ErrorIs(t, err, auth0.ErrNoKeyFound)
+}
+
+func Test_GivenJWKSManager_WhenKeySetChanges_ThenExpectTheBackgroundRefreshToPickItUp(t *testing.T) {
+	// Given
+	server := newJWKSServer(t, defaultSecret.Public())
+	manager, err := NewJWKSManager(context.Background(), server.URL, WithJWKSRefreshInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	defer manager.Close() //nolint: errcheck
+
+	// When
+	rotatedKey := genRSASSAJWK(jose.RS256, "rotated-kid")
+	server.setKeys(rotatedKey.Public())
+
+	// Then
+	assert.Eventually(t, func() bool {
+		keys := manager.KeySet().Keys
+		return len(keys) == 1 && keys[0].KeyID == "rotated-kid"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func Test_GivenUnreachableJWKSEndpoint_WhenJWKSManagerIsCreated_ThenExpectAnError(t *testing.T) {
+	// Given
+	server := newJWKSServer(t)
+
+	// When
+	_, err := NewJWKSManager(context.Background(), server.URL)
+
+	// Then
+	assert.EqualError(t, err, "the JWKS does not contain any keys")
+}
+
+func assertSameKey(t *testing.T, expected, actual jose.JSONWebKey) {
+	assert.Equal(t, expected.KeyID, actual.KeyID)
+	assert.True(t, expected.Public().Key.(*rsa.PublicKey).Equal(actual.Key), "the public keys differ")
+}
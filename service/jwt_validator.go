@@ -0,0 +1,164 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bitrise-io/go-auth0"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// parseableAlgorithms are all the algorithms a token can be parsed with, it is used when the key is looked up,
+// the accepted algorithms are enforced by signedTokenValidator.
+var parseableAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+	jose.HS256, jose.HS384, jose.HS512,
+}
+
+// signedTokenValidator verifies the signature of the bearer token and returns its verified payload,
+// the registered claims are checked by the Validator on the decoded payload.
+// The algorithm of the token must be one of the accepted algorithms and must fit the key:
+// if the JWK sets "alg" it must be the same, and the key type must be the one of the algorithm,
+// so a token signed with an HMAC algorithm is never verified with a public key.
+type signedTokenValidator struct {
+	secretProvider auth0.SecretProvider
+	algorithms     []jose.SignatureAlgorithm
+	tracer         trace.Tracer
+}
+
+// ValidateRequest ...
+func (v signedTokenValidator) ValidateRequest(r *http.Request) (*jwt.JSONWebToken, json.RawMessage, error) {
+	token, err := parseRequestToken(r, v.algorithms)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(token.Headers) < 1 {
+		return nil, nil, auth0.ErrNoJWTHeaders
+	}
+	alg := jose.SignatureAlgorithm(token.Headers[0].Algorithm)
+
+	_, keySpan := v.tracer.Start(r.Context(), keyLookupSpanName, trace.WithAttributes(kidAttributeKey.String(keyID(token))))
+	key, err := v.secretProvider.GetSecret(r)
+	recordResult(keySpan, err)
+	keySpan.End()
+	if err != nil {
+		return token, nil, err
+	}
+
+	if !keyAcceptsAlgorithm(key, alg) {
+		return token, nil, auth0.ErrInvalidAlgorithm
+	}
+
+	var payload json.RawMessage
+	if err := token.Claims(key, &payload); err != nil {
+		return token, nil, err
+	}
+
+	return token, payload, nil
+}
+
+// parseRequestToken parses the bearer token of the request, it returns auth0.ErrInvalidAlgorithm
+// if the token is signed with an algorithm that is not in the algorithms.
+func parseRequestToken(r *http.Request, algorithms []jose.SignatureAlgorithm) (*jwt.JSONWebToken, error) {
+	raw := rawTokenFromRequest(r)
+	if raw == "" {
+		return nil, auth0.ErrTokenNotFound
+	}
+
+	token, err := jwt.ParseSigned(raw, algorithms)
+	if err != nil {
+		if alg, ok := headerAlgorithm(raw); ok && !containsAlgorithm(algorithms, alg) {
+			return nil, auth0.ErrInvalidAlgorithm
+		}
+		return nil, fmt.Errorf("%w: %w", ErrMalformedToken, err)
+	}
+
+	return token, nil
+}
+
+// headerAlgorithm returns the "alg" of the JOSE header without verifying the token.
+func headerAlgorithm(raw string) (jose.SignatureAlgorithm, bool) {
+	encodedHeader, _, _ := strings.Cut(raw, ".")
+	decodedHeader, err := base64.RawURLEncoding.DecodeString(encodedHeader)
+	if err != nil {
+		return "", false
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+	}
+	if err := json.Unmarshal(decodedHeader, &header); err != nil {
+		return "", false
+	}
+
+	return jose.SignatureAlgorithm(header.Algorithm), true
+}
+
+func containsAlgorithm(algorithms []jose.SignatureAlgorithm, alg jose.SignatureAlgorithm) bool {
+	for _, a := range algorithms {
+		if a == alg {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keyAcceptsAlgorithm reports whether a token signed with the algorithm can be verified with the key.
+func keyAcceptsAlgorithm(key interface{}, alg jose.SignatureAlgorithm) bool {
+	switch jwk := key.(type) {
+	case jose.JSONWebKey:
+		return jwkAcceptsAlgorithm(&jwk, alg)
+	case *jose.JSONWebKey:
+		return jwk != nil && jwkAcceptsAlgorithm(jwk, alg)
+	}
+
+	switch alg {
+	case jose.RS256, jose.RS384, jose.RS512, jose.PS256, jose.PS384, jose.PS512:
+		_, ok := key.(*rsa.PublicKey)
+		return ok
+	case jose.ES256:
+		return isECDSAKey(key, elliptic.P256())
+	case jose.ES384:
+		return isECDSAKey(key, elliptic.P384())
+	case jose.ES512:
+		return isECDSAKey(key, elliptic.P521())
+	case jose.EdDSA:
+		_, ok := key.(ed25519.PublicKey)
+		return ok
+	case jose.HS256, jose.HS384, jose.HS512:
+		_, ok := key.([]byte)
+		return ok
+	default:
+		return false
+	}
+}
+
+func jwkAcceptsAlgorithm(jwk *jose.JSONWebKey, alg jose.SignatureAlgorithm) bool {
+	if jwk.Algorithm != "" && jwk.Algorithm != string(alg) {
+		return false
+	}
+	if jwk.Use != "" && jwk.Use != "sig" {
+		return false
+	}
+
+	return keyAcceptsAlgorithm(jwk.Key, alg)
+}
+
+func isECDSAKey(key interface{}, curve elliptic.Curve) bool {
+	ecdsaKey, ok := key.(*ecdsa.PublicKey)
+	return ok && ecdsaKey.Curve == curve
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/bitrise-io/go-auth0"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SignatureAlgorithmValidation(t *testing.T) {
+	rsaKey := genRSASSAJWK(jose.RS256, defaultKid)
+	rsaKeyWithoutAlg := rsaKey
+	rsaKeyWithoutAlg.Algorithm = ""
+	es256Key := genECDSAJWK(elliptic.P256(), jose.ES256)
+	es384KeyWithoutAlg := genECDSAJWK(elliptic.P384(), "")
+	edKey := genEdDSAJWK()
+	rsaPublicKeyDER, err := x509.MarshalPKIXPublicKey(rsaKey.Public().Key)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		algorithms    []jose.SignatureAlgorithm
+		tokenAlg      jose.SignatureAlgorithm
+		signingKey    interface{}
+		verifyingKey  jose.JSONWebKey
+		expectedError error
+	}{
+		{
+			name:         "Given the default algorithm when an RS256 token is validated then expect no error",
+			tokenAlg:     jose.RS256,
+			signingKey:   rsaKey,
+			verifyingKey: rsaKey.Public(),
+		},
+		{
+			name:         "Given RS256 and ES256 when an ES256 token is validated then expect no error",
+			algorithms:   []jose.SignatureAlgorithm{jose.RS256, jose.ES256},
+			tokenAlg:     jose.ES256,
+			signingKey:   es256Key,
+			verifyingKey: es256Key.Public(),
+		},
+		{
+			name:         "Given EdDSA when an EdDSA token is validated then expect no error",
+			algorithms:   []jose.SignatureAlgorithm{jose.RS256, jose.EdDSA},
+			tokenAlg:     jose.EdDSA,
+			signingKey:   edKey,
+			verifyingKey: edKey.Public(),
+		},
+		{
+			name:          "Given RS256 when a PS256 token is validated then expect an invalid algorithm error",
+			tokenAlg:      jose.PS256,
+			signingKey:    rsaKeyWithoutAlg,
+			verifyingKey:  rsaKeyWithoutAlg.Public(),
+			expectedError: auth0.ErrInvalidAlgorithm,
+		},
+		{
+			name:          "Given a JWK with RS256 alg when a PS256 token is validated then expect an invalid algorithm error",
+			algorithms:    []jose.SignatureAlgorithm{jose.RS256, jose.PS256},
+			tokenAlg:      jose.PS256,
+			signingKey:    rsaKeyWithoutAlg,
+			verifyingKey:  rsaKey.Public(),
+			expectedError: auth0.ErrInvalidAlgorithm,
+		},
+		{
+			name:          "Given a P-384 JWK without alg when an ES256 token is validated then expect an invalid algorithm error",
+			algorithms:    []jose.SignatureAlgorithm{jose.ES256, jose.ES384},
+			tokenAlg:      jose.ES256,
+			signingKey:    genECDSAJWK(elliptic.P256(), ""),
+			verifyingKey:  es384KeyWithoutAlg.Public(),
+			expectedError: auth0.ErrInvalidAlgorithm,
+		},
+		{
+			name:          "Given HS256 and an RSA JWK when a token is signed with the public key then expect an invalid algorithm error",
+			algorithms:    []jose.SignatureAlgorithm{jose.RS256, jose.HS256},
+			tokenAlg:      jose.HS256,
+			signingKey:    rsaPublicKeyDER,
+			verifyingKey:  rsaKeyWithoutAlg.Public(),
+			expectedError: auth0.ErrInvalidAlgorithm,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given
+			testToken := newTestTokenConfig()
+			testToken.alg = testCase.tokenAlg
+			testToken.key = testCase.signingKey
+			opts := []ValidatorOption{WithIssuer(defaultIssuer), withSecretProvider(auth0.NewKeyProvider(testCase.verifyingKey))}
+			if testCase.algorithms != nil {
+				opts = append(opts, WithSignatureAlgorithms(testCase.algorithms...))
+			}
+			validator := NewValidator(config.NewAudienceConfig(defaultAudience[0]), opts...)
+
+			// When
+			err := validator.ValidateRequest(testToken.newRequest())
+
+			// Then
+			if testCase.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, testCase.expectedError)
+			}
+		})
+	}
+}
+
+func Test_GivenUnsignedToken_WhenRequestIsValidated_ThenExpectAnInvalidAlgorithmError(t *testing.T) {
+	// Given
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"iss":%q,"aud":%q,"exp":%d}`,
+		defaultIssuer, defaultAudience[0], time.Now().Add(time.Hour).Unix())))
+	request := newTestTokenConfig().newRequest()
+	request.Header.Set(authorizationHeader, bearer+" "+header+"."+payload+".")
+
+	validator := NewValidator(config.NewAudienceConfig(defaultAudience[0]),
+		WithIssuer(defaultIssuer),
+		WithSignatureAlgorithms(jose.RS256, jose.ES256),
+		withSecretProvider(defaultSecretProvider))
+
+	// When
+	err := validator.ValidateRequest(request)
+
+	// Then
+	assert.ErrorIs(t, err, auth0.ErrInvalidAlgorithm)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func Test_GivenJWKSEndpointWithECDSAKey_WhenES256TokenIsValidated_ThenExpectNoError(t *testing.T) {
+	// Given
+	es256Key := genECDSAJWK(elliptic.P256(), jose.ES256)
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{es256Key.Public()}})
+	}))
+	defer jwksServer.Close()
+
+	testToken := newTestTokenConfig()
+	testToken.alg = jose.ES256
+	testToken.key = es256Key
+	validator := NewValidator(config.NewAudienceConfig(defaultAudience[0]),
+		WithIssuer(defaultIssuer),
+		WithJWKSUrl(jwksServer.URL),
+		WithSignatureAlgorithms(jose.ES256))
+
+	// When
+	err := validator.ValidateRequest(testToken.newRequest())
+
+	// Then
+	assert.NoError(t, err)
+}
+
+func genECDSAJWK(curve elliptic.Curve, alg jose.SignatureAlgorithm) jose.JSONWebKey {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	return jose.JSONWebKey{Key: key, KeyID: defaultKid, Use: "sig", Algorithm: string(alg)}
+}
+
+func genEdDSAJWK() jose.JSONWebKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	return jose.JSONWebKey{Key: key, KeyID: defaultKid, Use: "sig", Algorithm: string(jose.EdDSA)}
+}
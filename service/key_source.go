@@ -0,0 +1,210 @@
+package service
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/go-auth0"
+	"github.com/go-jose/go-jose/v4"
+)
+
+// keySource provides the current key set of a keySetSecretProvider.
+type keySource interface {
+	keySet() (jose.JSONWebKeySet, error)
+}
+
+// keySetSecretProvider looks up the key of the token in the key set of its source, it implements auth0.SecretProvider.
+// If singleKeyFallback is set and the token's key ID is not in the set, a set with a single key is used anyway,
+// for key sources (like PEM files) that don't have key IDs.
+type keySetSecretProvider struct {
+	source            keySource
+	singleKeyFallback bool
+}
+
+// GetSecret ...
+func (p keySetSecretProvider) GetSecret(r *http.Request) (interface{}, error) {
+	kid, err := requestKeyID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	keySet, err := p.source.keySet()
+	if err != nil {
+		return nil, err
+	}
+
+	if key, ok := lookupKey(keySet, kid); ok {
+		return key, nil
+	}
+
+	if p.singleKeyFallback && len(keySet.Keys) == 1 {
+		return keySet.Keys[0], nil
+	}
+
+	return nil, fmt.Errorf("%w: kid %q", auth0.ErrNoKeyFound, kid)
+}
+
+// requestKeyID returns the key ID (kid) of the token in the request.
+func requestKeyID(r *http.Request) (string, error) {
+	token, err := parseRequestToken(r, parseableAlgorithms)
+	if err != nil {
+		return "", err
+	}
+
+	if len(token.Headers) < 1 {
+		return "", auth0.ErrNoJWTHeaders
+	}
+
+	return token.Headers[0].KeyID, nil
+}
+
+type staticKeySource jose.JSONWebKeySet
+
+func (s staticKeySource) keySet() (jose.JSONWebKeySet, error) {
+	return jose.JSONWebKeySet(s), nil
+}
+
+// fileKeySource reads the key set from a file. The modification time of the file is checked at most once
+// per reloadInterval when a key is needed, and the file is parsed again when it changed, so rotated keys
+// (e.g. a mounted secret) are picked up without a restart. A zero interval disables reloading.
+// If the file becomes invalid, the previous keys are used.
+type fileKeySource struct {
+	path           string
+	reloadInterval time.Duration
+	parse          func([]byte) (jose.JSONWebKeySet, error)
+	now            func() time.Time
+
+	mu        sync.Mutex
+	keys      jose.JSONWebKeySet
+	err       error
+	loaded    bool
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func newFileKeySource(path string, reloadInterval time.Duration, parse func([]byte) (jose.JSONWebKeySet, error)) *fileKeySource {
+	source := &fileKeySource{
+		path:           path,
+		reloadInterval: reloadInterval,
+		parse:          parse,
+		now:            time.Now,
+	}
+	source.load()
+
+	return source
+}
+
+func (s *fileKeySource) keySet() (jose.JSONWebKeySet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reloadInterval > 0 && s.now().Sub(s.lastCheck) >= s.reloadInterval {
+		s.reloadIfChangedLocked()
+	}
+
+	if !s.loaded {
+		return jose.JSONWebKeySet{}, fmt.Errorf("%w: %w", ErrJWKSUnavailable, s.err)
+	}
+
+	return s.keys, nil
+}
+
+func (s *fileKeySource) load() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reloadIfChangedLocked()
+}
+
+func (s *fileKeySource) reloadIfChangedLocked() {
+	s.lastCheck = s.now()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		s.err = fmt.Errorf("failed to read the key file: %w", err)
+		return
+	}
+	if s.loaded && info.ModTime().Equal(s.modTime) {
+		return
+	}
+
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		s.err = fmt.Errorf("failed to read the key file: %w", err)
+		return
+	}
+
+	keys, err := s.parse(content)
+	// a broken version is not parsed again until the file changes
+	s.modTime = info.ModTime()
+	if err != nil {
+		s.err = fmt.Errorf("failed to parse the key file %s: %w", s.path, err)
+		return
+	}
+
+	s.keys, s.err, s.loaded = keys, nil, true
+}
+
+func parseJWKS(content []byte) (jose.JSONWebKeySet, error) {
+	return decodeKeySet(bytes.NewReader(content))
+}
+
+// parsePEMKeys parses the PEM encoded public keys ("PUBLIC KEY" or "RSA PUBLIC KEY") and certificates.
+// PEM keys have no key ID, so their RFC 7638 thumbprint is used.
+func parsePEMKeys(content []byte) (jose.JSONWebKeySet, error) {
+	var keySet jose.JSONWebKeySet
+
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			break
+		}
+
+		key, err := parsePEMBlock(block)
+		if err != nil {
+			return jose.JSONWebKeySet{}, err
+		}
+
+		jwk := jose.JSONWebKey{Key: key, Use: "sig"}
+		thumbprint, err := jwk.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return jose.JSONWebKeySet{}, fmt.Errorf("unsupported %s: %w", block.Type, err)
+		}
+		jwk.KeyID = base64.RawURLEncoding.EncodeToString(thumbprint)
+
+		keySet.Keys = append(keySet.Keys, jwk)
+	}
+
+	if len(keySet.Keys) == 0 {
+		return jose.JSONWebKeySet{}, errors.New("no PEM encoded keys found")
+	}
+
+	return keySet, nil
+}
+
+func parsePEMBlock(block *pem.Block) (interface{}, error) {
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"crypto"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/bitrise-io/go-auth0"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenStaticKeySet_WhenTokenIsValidated_ThenExpectTheKeyOfTheKidToBeUsed(t *testing.T) {
+	// Given
+	otherKey := genRSASSAJWK(jose.RS256, "other-kid")
+	validator := NewValidator(config.NewAudienceConfig(defaultAudience[0]),
+		WithIssuer(defaultIssuer),
+		WithKeySet(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{otherKey.Public(), defaultSecret.Public()}}))
+
+	testTokenWithUnknownKid := newTestTokenConfig()
+	testTokenWithUnknownKid.kid = "unknown-kid"
+
+	// When
+	err := validator.ValidateRequest(newTestTokenConfig().newRequest())
+	errUnknownKid := validator.ValidateRequest(testTokenWithUnknownKid.newRequest())
+
+	// Then
+	assert.NoError(t, err)
+	assert.ErrorIs(t, errUnknownKid, auth0.ErrNoKeyFound)
+}
+
+func Test_GivenJWKSFile_WhenItIsRotated_ThenExpectTheNewKeysToBeUsed(t *testing.T) {
+	// Given
+	jwksPath := filepath.Join(t.TempDir(), "jwks.json")
+	writeKeyFile(t, jwksPath, time.Now().Add(-time.Hour), jwksContent(t, defaultSecret.Public()))
+	validator := NewValidator(config.NewAudienceConfig(defaultAudience[0]),
+		WithIssuer(defaultIssuer),
+		WithJWKSFile(jwksPath, time.Nanosecond))
+
+	rotatedKey := genRSASSAJWK(jose.RS256, "rotated-kid")
+	rotatedToken := newTestTokenConfig()
+	rotatedToken.key = rotatedKey
+	rotatedToken.kid = "rotated-kid"
+
+	require.NoError(t, validator.ValidateRequest(newTestTokenConfig().newRequest()))
+	require.ErrorIs(t, validator.ValidateRequest(rotatedToken.newRequest()), auth0.ErrNoKeyFound)
+
+	// When
+	writeKeyFile(t, jwksPath, time.Now(), jwksContent(t, rotatedKey.Public()))
+
+	// Then
+	assert.NoError(t, validator.ValidateRequest(rotatedToken.newRequest()))
+	assert.ErrorIs(t, validator.ValidateRequest(newTestTokenConfig().newRequest()), auth0.ErrNoKeyFound)
+
+	// When
+	writeKeyFile(t, jwksPath, time.Now().Add(time.Hour), []byte("{"))
+
+	// Then
+	assert.NoError(t, validator.ValidateRequest(rotatedToken.newRequest()))
+}
+
+func Test_GivenPEMFileWithCertificate_WhenTokenIsValidated_ThenExpectItsKeyToBeUsed(t *testing.T) {
+	// Given
+	ecKey := genECDSAJWK(elliptic.P256(), jose.ES256)
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "auth"}, NotAfter: time.Now().Add(time.Hour)}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, ecKey.Public().Key, ecKey.Key)
+	require.NoError(t, err)
+
+	pemPath := filepath.Join(t.TempDir(), "keys.pem")
+	writeKeyFile(t, pemPath, time.Now(), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}))
+
+	testToken := newTestTokenConfig()
+	testToken.alg = jose.ES256
+	testToken.key = ecKey
+	testToken.kid = "kid-unknown-to-the-pem-file"
+	validator := NewValidator(config.NewAudienceConfig(defaultAudience[0]),
+		WithIssuer(defaultIssuer),
+		WithSignatureAlgorithms(jose.ES256),
+		WithPEMFile(pemPath, 0))
+
+	// When
+	err = validator.ValidateRequest(testToken.newRequest())
+
+	// Then
+	assert.NoError(t, err)
+}
+
+func Test_GivenPEMFileWithMultipleKeys_WhenTokenIsValidated_ThenExpectTheThumbprintToSelectTheKey(t *testing.T) {
+	// Given
+	otherKey := genRSASSAJWK(jose.RS256, "other-kid")
+	pemPath := filepath.Join(t.TempDir(), "keys.pem")
+	writeKeyFile(t, pemPath, time.Now(), append(publicKeyPEM(t, otherKey), publicKeyPEM(t, defaultSecret)...))
+
+	publicKey := defaultSecret.Public()
+	thumbprint, err := publicKey.Thumbprint(crypto.SHA256)
+	require.NoError(t, err)
+	testToken := newTestTokenConfig()
+	testToken.kid = base64.RawURLEncoding.EncodeToString(thumbprint)
+	validator := NewValidator(config.NewAudienceConfig(defaultAudience[0]),
+		WithIssuer(defaultIssuer),
+		WithPEMFile(pemPath, 0))
+
+	// When
+	err = validator.ValidateRequest(testToken.newRequest())
+	errUnknownKid := validator.ValidateRequest(newTestTokenConfig().newRequest())
+
+	// Then
+	assert.NoError(t, err)
+	assert.ErrorIs(t, errUnknownKid, auth0.ErrNoKeyFound)
+}
+
+func Test_GivenMissingKeyFile_WhenTokenIsValidated_ThenExpectAnError(t *testing.T) {
+	// Given
+	validator := NewValidator(config.NewAudienceConfig(defaultAudience[0]),
+		WithIssuer(defaultIssuer),
+		WithJWKSFile(filepath.Join(t.TempDir(), "missing.json"), time.Minute))
+
+	// When
+	err := validator.ValidateRequest(newTestTokenConfig().newRequest())
+
+	// Then
+	assert.ErrorContains(t, err, "failed to read the key file")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func jwksContent(t *testing.T, keys ...jose.JSONWebKey) []byte {
+	content, err := json.Marshal(jose.JSONWebKeySet{Keys: keys})
+	require.NoError(t, err)
+
+	return content
+}
+
+func publicKeyPEM(t *testing.T, key jose.JSONWebKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key.Public().Key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func writeKeyFile(t *testing.T, path string, modTime time.Time, content []byte) {
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+)
+
+// validateFunc validates the request and returns its token, it is shared by the middlewares of the Validator implementations.
+type validateFunc func(r *http.Request) (TokenWithClaims, error)
+
+func httpMiddleware(validate validateFunc, next http.Handler, opts ...HTTPMiddlewareOption) http.Handler {
+	handlerConfig := &HTTPMiddlewareConfig{
+		errorWriter: defaultHTTPErrorWriter,
+	}
+
+	for _, opt := range opts {
+		opt(handlerConfig)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := validate(r)
+		if err == nil {
+			err = checkRequirements(token, handlerConfig.requirements)
+		}
+		if err != nil {
+			handlerConfig.errorWriter(w, r, err)
+			return
+		}
+		if handlerConfig.tokenHandler != nil {
+			handlerConfig.tokenHandler(w, r, token)
+		}
+		next.ServeHTTP(w, requestWithToken(r, token))
+	})
+}
+
+func echoMiddlewareFunc(validate validateFunc, opts ...EchoMiddlewareOption) echo.MiddlewareFunc {
+	handlerConfig := &EchoMiddlewareConfig{
+		errorWriter: defaultEchoErrorWriter,
+	}
+
+	for _, opt := range opts {
+		opt(handlerConfig)
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token, err := validate(c.Request())
+			if err == nil {
+				err = checkRequirements(token, handlerConfig.requirements)
+			}
+			if err != nil {
+				return handlerConfig.errorWriter(c, err)
+			}
+			c.Set(TokenEchoKey, token)
+			c.SetRequest(requestWithToken(c.Request(), token))
+			return next(c)
+		}
+	}
+}
+
+func httpHandlerFunc(validate validateFunc, hf http.HandlerFunc, opts ...HTTPMiddlewareOption) http.HandlerFunc {
+	handlerConfig := &HTTPMiddlewareConfig{
+		errorWriter: defaultHTTPErrorWriter,
+	}
+
+	for _, opt := range opts {
+		opt(handlerConfig)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := validate(r)
+		if err == nil {
+			err = checkRequirements(token, handlerConfig.requirements)
+		}
+		if err != nil {
+			handlerConfig.errorWriter(w, r, err)
+			return
+		}
+		hf(w, requestWithToken(r, token))
+	}
+}
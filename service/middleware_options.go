@@ -6,9 +6,7 @@ import (
 	"github.com/labstack/echo"
 )
 
-var defaultHTTPErrorWriter = func(w http.ResponseWriter, r *http.Request, err error) {
-	http.Error(w, err.Error(), http.StatusUnauthorized)
-}
+var defaultHTTPErrorWriter = NewBearerErrorWriter()
 
 // HTTPMiddlewareOption ...
 type HTTPMiddlewareOption func(c *HTTPMiddlewareConfig)
@@ -17,6 +15,7 @@ type HTTPMiddlewareOption func(c *HTTPMiddlewareConfig)
 type HTTPMiddlewareConfig struct {
 	errorWriter  func(w http.ResponseWriter, r *http.Request, err error)
 	tokenHandler func(w http.ResponseWriter, r *http.Request, token TokenWithClaims)
+	requirements []tokenRequirement
 }
 
 // WithHTTPErrorWriter ...
@@ -33,8 +32,54 @@ func WithTokenHandler(tokenHandler func(w http.ResponseWriter, r *http.Request,
 	}
 }
 
-var defaultEchoErrorWriter = func(c echo.Context, err error) error {
-	return err
+// RequireScopes requires ALL the scopes in the scope claim of the token, otherwise the error writer
+// gets an error wrapping ErrInsufficientScope.
+func RequireScopes(scopes ...string) HTTPMiddlewareOption {
+	return func(c *HTTPMiddlewareConfig) {
+		c.requirements = append(c.requirements, allScopesRequirement(scopes))
+	}
+}
+
+// RequireAnyScope requires at least one of the scopes in the scope claim of the token.
+func RequireAnyScope(scopes ...string) HTTPMiddlewareOption {
+	return func(c *HTTPMiddlewareConfig) {
+		c.requirements = append(c.requirements, anyScopeRequirement(scopes))
+	}
+}
+
+// RequirePermission requires ALL the scopes in the permission of the resource.
+func RequirePermission(resourceName string, scopes ...string) HTTPMiddlewareOption {
+	return func(c *HTTPMiddlewareConfig) {
+		c.requirements = append(c.requirements, permissionRequirement(resourceName, scopes))
+	}
+}
+
+var defaultEchoErrorWriter = NewEchoBearerErrorWriter()
+
+// bearerRealmer is implemented by the validators that know the realm of their WWW-Authenticate challenges.
+type bearerRealmer interface {
+	bearerRealm() string
+}
+
+// withBearerRealm prepends the default error writer with the realm of the validator (if it has one),
+// so the challenges carry the realm unless the options override the error writer.
+func withBearerRealm(validator interface{}, opts []HTTPMiddlewareOption) []HTTPMiddlewareOption {
+	realmer, ok := validator.(bearerRealmer)
+	if !ok || realmer.bearerRealm() == "" {
+		return opts
+	}
+
+	return append([]HTTPMiddlewareOption{WithHTTPErrorWriter(NewBearerErrorWriter(WithBearerRealm(realmer.bearerRealm())))}, opts...)
+}
+
+// withEchoBearerRealm is withBearerRealm for the Echo middleware.
+func withEchoBearerRealm(validator interface{}, opts []EchoMiddlewareOption) []EchoMiddlewareOption {
+	realmer, ok := validator.(bearerRealmer)
+	if !ok || realmer.bearerRealm() == "" {
+		return opts
+	}
+
+	return append([]EchoMiddlewareOption{WithContextErrorWriter(NewEchoBearerErrorWriter(WithBearerRealm(realmer.bearerRealm())))}, opts...)
 }
 
 // EchoMiddlewareOption ...
@@ -42,7 +87,8 @@ type EchoMiddlewareOption func(c *EchoMiddlewareConfig)
 
 // EchoMiddlewareConfig ...
 type EchoMiddlewareConfig struct {
-	errorWriter func(echo.Context, error) error
+	errorWriter  func(echo.Context, error) error
+	requirements []tokenRequirement
 }
 
 // WithContextErrorWriter ...
@@ -51,3 +97,24 @@ func WithContextErrorWriter(errorWriter func(echo.Context, error) error) EchoMid
 		c.errorWriter = errorWriter
 	}
 }
+
+// EchoRequireScopes is the RequireScopes option of the Echo middleware.
+func EchoRequireScopes(scopes ...string) EchoMiddlewareOption {
+	return func(c *EchoMiddlewareConfig) {
+		c.requirements = append(c.requirements, allScopesRequirement(scopes))
+	}
+}
+
+// EchoRequireAnyScope is the RequireAnyScope option of the Echo middleware.
+func EchoRequireAnyScope(scopes ...string) EchoMiddlewareOption {
+	return func(c *EchoMiddlewareConfig) {
+		c.requirements = append(c.requirements, anyScopeRequirement(scopes))
+	}
+}
+
+// EchoRequirePermission is the RequirePermission option of the Echo middleware.
+func EchoRequirePermission(resourceName string, scopes ...string) EchoMiddlewareOption {
+	return func(c *EchoMiddlewareConfig) {
+		c.requirements = append(c.requirements, permissionRequirement(resourceName, scopes))
+	}
+}
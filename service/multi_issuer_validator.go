@@ -0,0 +1,106 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+)
+
+// ValidationMetrics records the result of the validations per issuer, it is implemented by metrics.DatadogMetrics.
+type ValidationMetrics interface {
+	IncrAuthValidationSucceededMetric(issuer string)
+	IncrAuthValidationFailedMetric(issuer string)
+}
+
+// ValidationFailureReasonMetrics can be implemented by the ValidationMetrics to record the failures with their
+// reason (see ErrorReason) too, it is implemented by metrics.DatadogMetrics.
+type ValidationFailureReasonMetrics interface {
+	IncrAuthValidationFailedWithReasonMetric(issuer, reason string)
+}
+
+// MultiIssuerValidatorOption ...
+type MultiIssuerValidatorOption func(v *multiIssuerValidator)
+
+// WithValidationMetrics records the success or failure of every validation, tagged with the issuer of the token.
+// The tokens of unregistered issuers are recorded with an empty issuer, which metrics.DatadogMetrics tags as unknown.
+func WithValidationMetrics(metrics ValidationMetrics) MultiIssuerValidatorOption {
+	return func(v *multiIssuerValidator) {
+		v.metrics = metrics
+	}
+}
+
+// multiIssuerValidator dispatches the validation of each request to the validator of the token's issuer.
+type multiIssuerValidator struct {
+	repository JwtValidatorRepository
+	metrics    ValidationMetrics
+}
+
+// NewMultiIssuerValidator returns a Validator that selects the validator for each request from the repository,
+// based on the "iss" claim of the token. Tokens of unknown issuers are rejected with ErrUnknownIssuer.
+func NewMultiIssuerValidator(repository JwtValidatorRepository, opts ...MultiIssuerValidatorOption) Validator {
+	v := &multiIssuerValidator{
+		repository: repository,
+	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+// ValidateRequest ...
+func (v *multiIssuerValidator) ValidateRequest(r *http.Request) error {
+	_, err := v.ValidateRequestAndReturnToken(r)
+	return err
+}
+
+// ValidateRequestAndReturnToken ...
+func (v *multiIssuerValidator) ValidateRequestAndReturnToken(r *http.Request) (TokenWithClaims, error) {
+	validator, issuer, err := v.repository.GetJwtValidatorForRequest(r)
+	if err != nil {
+		// the issuer of the token is not verified and not registered, so it is not used as a metric tag:
+		// the failures are recorded with an empty (unknown) issuer to keep the number of tags bounded
+		err = newValidationError(err)
+		v.recordResult("", err)
+		return nil, err
+	}
+
+	token, err := validator.ValidateRequestAndReturnToken(r)
+	err = newValidationError(err)
+	v.recordResult(issuer, err)
+
+	return token, err
+}
+
+func (v *multiIssuerValidator) recordResult(issuer string, err error) {
+	if v.metrics == nil {
+		return
+	}
+
+	if err != nil {
+		if reasonMetrics, ok := v.metrics.(ValidationFailureReasonMetrics); ok {
+			reasonMetrics.IncrAuthValidationFailedWithReasonMetric(issuer, ErrorReason(err))
+			return
+		}
+		v.metrics.IncrAuthValidationFailedMetric(issuer)
+		return
+	}
+
+	v.metrics.IncrAuthValidationSucceededMetric(issuer)
+}
+
+// Middleware ...
+func (v *multiIssuerValidator) Middleware(next http.Handler, opts ...HTTPMiddlewareOption) http.Handler {
+	return httpMiddleware(v.ValidateRequestAndReturnToken, next, opts...)
+}
+
+// EchoMiddlewareFunc ...
+func (v *multiIssuerValidator) EchoMiddlewareFunc(opts ...EchoMiddlewareOption) echo.MiddlewareFunc {
+	return echoMiddlewareFunc(v.ValidateRequestAndReturnToken, opts...)
+}
+
+// HandlerFunc ...
+func (v *multiIssuerValidator) HandlerFunc(hf http.HandlerFunc, opts ...HTTPMiddlewareOption) http.HandlerFunc {
+	return httpHandlerFunc(v.ValidateRequestAndReturnToken, hf, opts...)
+}
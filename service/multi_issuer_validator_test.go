@@ -0,0 +1,136 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/bitrise-io/bitrise-oauth/metrics"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ ValidationMetrics = &metrics.DatadogMetrics{}
+
+const otherIssuer = "other-issuer"
+
+type fakeValidationMetrics struct {
+	succeeded []string
+	failed    []string
+}
+
+func (m *fakeValidationMetrics) IncrAuthValidationSucceededMetric(issuer string) {
+	m.succeeded = append(m.succeeded, issuer)
+}
+
+func (m *fakeValidationMetrics) IncrAuthValidationFailedMetric(issuer string) {
+	m.failed = append(m.failed, issuer)
+}
+
+type fakeValidationReasonMetrics struct {
+	fakeValidationMetrics
+	reasons []string
+}
+
+func (m *fakeValidationReasonMetrics) IncrAuthValidationFailedWithReasonMetric(issuer, reason string) {
+	m.failed = append(m.failed, issuer)
+	m.reasons = append(m.reasons, reason)
+}
+
+func givenMultiIssuerValidator(validationMetrics ValidationMetrics) Validator {
+	repository := NewJwtValidatorRepository(map[string]Validator{
+		defaultIssuer: NewValidator(config.NewAudienceConfig(defaultAudience[0]),
+			WithIssuer(defaultIssuer), withSecretProvider(defaultSecretProvider)),
+		otherIssuer: NewValidator(config.NewAudienceConfig("other-audience"),
+			WithIssuer(otherIssuer), withSecretProvider(defaultSecretProvider)),
+	})
+
+	return NewMultiIssuerValidator(repository, WithValidationMetrics(validationMetrics))
+}
+
+func newTestTokenConfigWithIssuer(issuer string) testTokenConfig {
+	testToken := newTestTokenConfig()
+	testToken.issuer = issuer
+	return testToken
+}
+
+func Test_GivenMultiIssuerValidator_WhenRequestsAreValidated_ThenExpectTheIssuersValidatorToBeUsed(t *testing.T) {
+	// Given
+	validationMetrics := &fakeValidationMetrics{}
+	validator := givenMultiIssuerValidator(validationMetrics)
+
+	// When
+	errKnownIssuer := validator.ValidateRequest(newTestTokenConfigWithIssuer(defaultIssuer).newRequest())
+	errWrongAudience := validator.ValidateRequest(newTestTokenConfigWithIssuer(otherIssuer).newRequest())
+	_, errUnknownIssuer := validator.ValidateRequestAndReturnToken(newTestTokenConfigWithIssuer("unknown-issuer").newRequest())
+	errNoToken := validator.ValidateRequest(httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// Then
+	require.NoError(t, errKnownIssuer)
+	assert.Error(t, errWrongAudience)
+	assert.ErrorIs(t, errUnknownIssuer, ErrUnknownIssuer)
+	assert.Error(t, errNoToken)
+
+	assert.Equal(t, []string{defaultIssuer}, validationMetrics.succeeded)
+	assert.Equal(t, []string{otherIssuer, "", ""}, validationMetrics.failed)
+}
+
+func Test_GivenReasonMetrics_WhenRequestsAreRejected_ThenExpectTheReasonsToBeRecorded(t *testing.T) {
+	// Given
+	validationMetrics := &fakeValidationReasonMetrics{}
+	validator := givenMultiIssuerValidator(validationMetrics)
+
+	// When
+	errWrongAudience := validator.ValidateRequest(newTestTokenConfigWithIssuer(otherIssuer).newRequest())
+	errUnknownIssuer := validator.ValidateRequest(newTestTokenConfigWithIssuer("unknown-issuer").newRequest())
+	errNoToken := validator.ValidateRequest(httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// Then
+	assert.ErrorIs(t, errWrongAudience, ErrAudienceMismatch)
+	assert.ErrorIs(t, errUnknownIssuer, ErrIssuerMismatch)
+	assert.ErrorIs(t, errNoToken, ErrMissingToken)
+	assert.Equal(t, []string{otherIssuer, "", ""}, validationMetrics.failed)
+	assert.Equal(t, []string{"invalid_audience", "invalid_issuer", "token_not_found"}, validationMetrics.reasons)
+}
+
+func Test_GivenMultiIssuerValidator_WhenMiddlewaresAreCalled_ThenExpectUnknownIssuersToBeRejected(t *testing.T) {
+	// Given
+	validator := givenMultiIssuerValidator(&fakeValidationMetrics{})
+	knownRequest := newTestTokenConfigWithIssuer(defaultIssuer).newRequest()
+	unknownRequest := newTestTokenConfigWithIssuer("unknown-issuer").newRequest()
+
+	var handled int
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		handled++
+	}
+	var errs []error
+	errorWriter := func(w http.ResponseWriter, r *http.Request, err error) {
+		errs = append(errs, err)
+	}
+
+	// When
+	for _, request := range []*http.Request{knownRequest, unknownRequest} {
+		validator.Middleware(http.HandlerFunc(handler), WithHTTPErrorWriter(errorWriter)).ServeHTTP(httptest.NewRecorder(), request)
+		validator.HandlerFunc(handler, WithHTTPErrorWriter(errorWriter))(httptest.NewRecorder(), request)
+	}
+
+	echoErrorWriter := func(c echo.Context, err error) error {
+		return err
+	}
+	echoHandler := validator.EchoMiddlewareFunc(WithContextErrorWriter(echoErrorWriter))(func(c echo.Context) error {
+		handled++
+		return nil
+	})
+	errEchoKnown := echoHandler(echo.New().NewContext(knownRequest, httptest.NewRecorder()))
+	errEchoUnknown := echoHandler(echo.New().NewContext(unknownRequest, httptest.NewRecorder()))
+
+	// Then
+	assert.Equal(t, 3, handled)
+	require.Len(t, errs, 2)
+	for _, err := range append(errs, errEchoUnknown) {
+		assert.ErrorIs(t, err, ErrUnknownIssuer)
+	}
+	assert.NoError(t, errEchoKnown)
+}
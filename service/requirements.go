@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInsufficientScope is returned by the middlewares when the valid token does not have the required scopes
+// or permissions, the default error writers respond with 403 Forbidden to it.
+var ErrInsufficientScope = errors.New("insufficient_scope")
+
+// tokenRequirement checks the authorization of a validated token.
+type tokenRequirement func(token TokenWithClaims) error
+
+func checkRequirements(token TokenWithClaims, requirements []tokenRequirement) error {
+	for _, requirement := range requirements {
+		if err := requirement(token); err != nil {
+			return newValidationError(err)
+		}
+	}
+
+	return nil
+}
+
+// allScopesRequirement requires ALL the scopes in the scope claim of the token.
+func allScopesRequirement(scopes []string) tokenRequirement {
+	return func(token TokenWithClaims) error {
+		if err := token.ValidateScopes(scopes); err != nil {
+			return fmt.Errorf("%w: %w", ErrInsufficientScope, err)
+		}
+
+		return nil
+	}
+}
+
+// anyScopeRequirement requires at least one of the scopes in the scope claim of the token.
+func anyScopeRequirement(scopes []string) tokenRequirement {
+	return func(token TokenWithClaims) error {
+		claims, err := token.StandardClaims()
+		if err != nil {
+			return err
+		}
+
+		for _, scope := range scopes {
+			if claims.HasScope(scope) {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("%w: none of the scopes %s is in the token", ErrInsufficientScope, strings.Join(scopes, ", "))
+	}
+}
+
+// permissionRequirement requires ALL the scopes in the permission of the resource.
+// Unlike ValidatePermissionScopes it looks through all the permissions of the token and fails if none of them
+// belongs to the resource.
+func permissionRequirement(resourceName string, scopes []string) tokenRequirement {
+	return func(token TokenWithClaims) error {
+		uma, err := umaClaims(token)
+		if err != nil {
+			return err
+		}
+
+		for _, permission := range uma.Authorization.Permissions {
+			if permission.Rsname != resourceName {
+				continue
+			}
+
+			for _, scope := range scopes {
+				if !contains(permission.Scopes, scope) {
+					return fmt.Errorf("%w: scope %s of resource %s is missing from permissions", ErrInsufficientScope, scope, resourceName)
+				}
+			}
+
+			return nil
+		}
+
+		return fmt.Errorf("%w: no permission for resource %s in the token", ErrInsufficientScope, resourceName)
+	}
+}
+
+// umaClaims returns the UMA claims of the token, the tokens of the validators have them decoded already.
+func umaClaims(token TokenWithClaims) (umaToken, error) {
+	if t, ok := token.(*tokenWithClaims); ok {
+		return t.uma, t.umaErr
+	}
+
+	uma := umaToken{}
+	err := token.DecodePayload(&uma)
+
+	return uma, err
+}
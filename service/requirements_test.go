@@ -0,0 +1,133 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MiddlewareRequirements(t *testing.T) {
+	claims := map[string]interface{}{
+		"scope": "app:read build:write",
+		"authorization": authorization{
+			Permissions: []permisson{
+				{Rsname: "apps", Scopes: []string{"read"}},
+				{Rsname: "builds", Scopes: []string{"read", "write"}},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name               string
+		httpOpts           []HTTPMiddlewareOption
+		echoOpts           []EchoMiddlewareOption
+		expectedStatusCode int
+	}{
+		{
+			name:               "Given no requirements then expect the request to be handled",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Given all the required scopes are in the token then expect the request to be handled",
+			httpOpts:           []HTTPMiddlewareOption{RequireScopes("build:write", "app:read")},
+			echoOpts:           []EchoMiddlewareOption{EchoRequireScopes("build:write", "app:read")},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Given a required scope is missing then expect forbidden",
+			httpOpts:           []HTTPMiddlewareOption{RequireScopes("app:read", "app:write")},
+			echoOpts:           []EchoMiddlewareOption{EchoRequireScopes("app:read", "app:write")},
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Given one of the scopes is in the token then expect the request to be handled",
+			httpOpts:           []HTTPMiddlewareOption{RequireAnyScope("app:write", "app:read")},
+			echoOpts:           []EchoMiddlewareOption{EchoRequireAnyScope("app:write", "app:read")},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Given none of the scopes is in the token then expect forbidden",
+			httpOpts:           []HTTPMiddlewareOption{RequireAnyScope("app:write", "build:read")},
+			echoOpts:           []EchoMiddlewareOption{EchoRequireAnyScope("app:write", "build:read")},
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Given the permission of a later resource has the scopes then expect the request to be handled",
+			httpOpts:           []HTTPMiddlewareOption{RequirePermission("builds", "write")},
+			echoOpts:           []EchoMiddlewareOption{EchoRequirePermission("builds", "write")},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Given the permission misses a scope then expect forbidden",
+			httpOpts:           []HTTPMiddlewareOption{RequirePermission("apps", "write")},
+			echoOpts:           []EchoMiddlewareOption{EchoRequirePermission("apps", "write")},
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Given there is no permission for the resource then expect forbidden",
+			httpOpts:           []HTTPMiddlewareOption{RequirePermission("pipelines", "read")},
+			echoOpts:           []EchoMiddlewareOption{EchoRequirePermission("pipelines", "read")},
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Given multiple requirements when one of them fails then expect forbidden",
+			httpOpts:           []HTTPMiddlewareOption{RequireScopes("app:read"), RequirePermission("apps", "write")},
+			echoOpts:           []EchoMiddlewareOption{EchoRequireScopes("app:read"), EchoRequirePermission("apps", "write")},
+			expectedStatusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given
+			token := givenTokenWithClaims(claims)
+			validate := func(r *http.Request) (TokenWithClaims, error) {
+				return &token, nil
+			}
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}
+
+			// When
+			middlewareRecorder := httptest.NewRecorder()
+			httpMiddleware(validate, http.HandlerFunc(handler), testCase.httpOpts...).
+				ServeHTTP(middlewareRecorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			handlerFuncRecorder := httptest.NewRecorder()
+			httpHandlerFunc(validate, handler, testCase.httpOpts...)(handlerFuncRecorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			echoRecorder := httptest.NewRecorder()
+			e := echo.New()
+			e.Use(echoMiddlewareFunc(validate, testCase.echoOpts...))
+			e.GET("/", func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+			e.ServeHTTP(echoRecorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			// Then
+			assert.Equal(t, testCase.expectedStatusCode, middlewareRecorder.Code)
+			assert.Equal(t, testCase.expectedStatusCode, handlerFuncRecorder.Code)
+			assert.Equal(t, testCase.expectedStatusCode, echoRecorder.Code)
+			if testCase.expectedStatusCode == http.StatusForbidden {
+				assert.Contains(t, middlewareRecorder.Body.String(), "insufficient_scope")
+			}
+		})
+	}
+}
+
+func Test_GivenTokenWithoutScopeClaim_WhenScopesAreRequired_ThenExpectInsufficientScopeError(t *testing.T) {
+	// Given
+	token := givenTokenWithClaims(struct{}{})
+
+	// When
+	errAll := allScopesRequirement([]string{"app:read"})(&token)
+	errAny := anyScopeRequirement([]string{"app:read"})(&token)
+
+	// Then
+	require.ErrorIs(t, errAll, ErrInsufficientScope)
+	require.ErrorIs(t, errAny, ErrInsufficientScope)
+}
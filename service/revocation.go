@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RevocationKeyType is the claim a revocation is keyed by.
+type RevocationKeyType string
+
+// The claims a token can be revoked by.
+const (
+	// RevokeByTokenID revokes a single token by its ID (jti).
+	RevokeByTokenID RevocationKeyType = "jti"
+	// RevokeBySessionID revokes the tokens of a session (sid).
+	RevokeBySessionID RevocationKeyType = "sid"
+	// RevokeBySubject revokes the tokens of a user or a service account (sub).
+	RevokeBySubject RevocationKeyType = "sub"
+	// RevokeByClientID revokes the tokens of a client (client_id, or azp if the token has no client_id).
+	RevokeByClientID RevocationKeyType = "client_id"
+)
+
+// RevocationKey identifies the revoked tokens, e.g. RevocationKey{Type: RevokeBySubject, Value: "user-id"}.
+type RevocationKey struct {
+	Type  RevocationKeyType
+	Value string
+}
+
+// RevocationChecker is consulted by the Validator after the signature of the token was verified,
+// and on every hit of the verified-token cache. Revoked tokens are rejected with ErrTokenRevoked,
+// if the checker returns an error the tokens are rejected with ErrRevocationCheckFailed.
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, token TokenWithClaims) (bool, error)
+}
+
+// RevocationStore is a store of the revocations, e.g. an adapter of a Redis or a database shared by the
+// instances of a service, see NewRevocationStoreChecker. The store should drop the revocations when they expire.
+type RevocationStore interface {
+	// RevokedAt returns the time the key was revoked at, found is false if it is not revoked or the revocation expired.
+	RevokedAt(ctx context.Context, key RevocationKey) (revokedAt time.Time, found bool, err error)
+}
+
+// revocationClaims are the claims the tokens can be revoked by.
+type revocationClaims struct {
+	StandardClaims
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// NewRevocationStoreChecker returns a RevocationChecker that looks up the jti, sid, sub and client ID of the tokens
+// in the store. A key revokes the tokens issued (iat) before or at the time of the revocation, so the tokens issued
+// later, e.g. after the user logged in again, are accepted. Tokens without iat are always revoked by their keys.
+func NewRevocationStoreChecker(store RevocationStore) RevocationChecker {
+	return storeRevocationChecker{store: store}
+}
+
+type storeRevocationChecker struct {
+	store RevocationStore
+}
+
+// IsRevoked ...
+func (c storeRevocationChecker) IsRevoked(ctx context.Context, token TokenWithClaims) (bool, error) {
+	claims, err := DecodeClaims[revocationClaims](token)
+	if err != nil {
+		return false, err
+	}
+
+	for _, key := range revocationKeys(claims) {
+		revokedAt, found, err := c.store.RevokedAt(ctx, key)
+		if err != nil {
+			return false, err
+		}
+		if found && (claims.IssuedAt == nil || !claims.IssuedAt.Time().After(revokedAt)) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func revocationKeys(claims revocationClaims) []RevocationKey {
+	clientID := claims.ClientID
+	if clientID == "" {
+		clientID = claims.AuthorizedParty
+	}
+
+	var keys []RevocationKey
+	for _, key := range []RevocationKey{
+		{RevokeByTokenID, claims.ID},
+		{RevokeBySessionID, claims.SessionID},
+		{RevokeBySubject, claims.Subject},
+		{RevokeByClientID, clientID},
+	} {
+		if key.Value != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// checkRevocation returns ErrTokenRevoked if the token is revoked.
+func checkRevocation(ctx context.Context, checker RevocationChecker, token TokenWithClaims) error {
+	revoked, err := checker.IsRevoked(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRevocationCheckFailed, err)
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// MemoryRevocationList is an in-memory RevocationStore and RevocationChecker, for a single instance of a service
+// or as a local copy of a shared deny-list. The expired revocations are removed periodically. It is safe for
+// concurrent use.
+type MemoryRevocationList struct {
+	mu          sync.RWMutex
+	revocations map[RevocationKey]revocation
+	clock       func() time.Time
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+type revocation struct {
+	revokedAt time.Time
+	expiresAt time.Time
+}
+
+// NewMemoryRevocationList returns an empty revocation list, the expired revocations are removed every cleanupInterval
+// until the list is closed. A zero interval disables the cleanup, the expired revocations are ignored anyway.
+func NewMemoryRevocationList(cleanupInterval time.Duration) *MemoryRevocationList {
+	l := &MemoryRevocationList{
+		revocations: map[RevocationKey]revocation{},
+		clock:       time.Now,
+		stop:        make(chan struct{}),
+	}
+
+	if cleanupInterval > 0 {
+		go l.cleanupPeriodically(cleanupInterval)
+	}
+
+	return l
+}
+
+// Revoke revokes the tokens of the key issued until now. The revocation expires at expiresAt, which should be
+// later than the expiration of the last token of the key, e.g. now plus the maximum lifetime of the tokens.
+func (l *MemoryRevocationList) Revoke(key RevocationKey, expiresAt time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.revocations[key] = revocation{revokedAt: l.clock(), expiresAt: expiresAt}
+}
+
+// Unrevoke removes the revocation of the key.
+func (l *MemoryRevocationList) Unrevoke(key RevocationKey) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.revocations, key)
+}
+
+// RevokedAt implements RevocationStore.
+func (l *MemoryRevocationList) RevokedAt(_ context.Context, key RevocationKey) (time.Time, bool, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	r, ok := l.revocations[key]
+	if !ok || !l.clock().Before(r.expiresAt) {
+		return time.Time{}, false, nil
+	}
+
+	return r.revokedAt, true, nil
+}
+
+// IsRevoked implements RevocationChecker, see NewRevocationStoreChecker.
+func (l *MemoryRevocationList) IsRevoked(ctx context.Context, token TokenWithClaims) (bool, error) {
+	return storeRevocationChecker{store: l}.IsRevoked(ctx, token)
+}
+
+// Close stops the periodic cleanup.
+func (l *MemoryRevocationList) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.stop)
+	})
+
+	return nil
+}
+
+func (l *MemoryRevocationList) cleanupPeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			l.cleanup()
+		}
+	}
+}
+
+// cleanup removes the expired revocations.
+func (l *MemoryRevocationList) cleanup() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock()
+	for key, r := range l.revocations {
+		if !now.Before(r.expiresAt) {
+			delete(l.revocations, key)
+		}
+	}
+}
+
+func (l *MemoryRevocationList) count() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return len(l.revocations)
+}
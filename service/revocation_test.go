@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type revocationStoreFunc func(ctx context.Context, key RevocationKey) (time.Time, bool, error)
+
+func (f revocationStoreFunc) RevokedAt(ctx context.Context, key RevocationKey) (time.Time, bool, error) {
+	return f(ctx, key)
+}
+
+func givenRevocationCheckingValidator(checker RevocationChecker, opts ...ValidatorOption) Validator {
+	return NewValidator(
+		config.NewAudienceConfig(defaultAudience[0]),
+		append([]ValidatorOption{
+			WithIssuer(defaultIssuer),
+			withSecretProvider(defaultSecretProvider),
+			WithRevocationChecker(checker),
+		}, opts...)...,
+	)
+}
+
+func givenRevocableClaims(issuedAt time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":       defaultIssuer,
+		"aud":       defaultAudience,
+		"exp":       issuedAt.Add(time.Hour).Unix(),
+		"iat":       issuedAt.Unix(),
+		"jti":       "token-id",
+		"sid":       "session-id",
+		"sub":       "user-id",
+		"client_id": "client-id",
+	}
+}
+
+func Test_GivenRevokedKey_WhenTheTokenIsValidated_ThenExpectARevokedTokenError(t *testing.T) {
+	testCases := []struct {
+		name string
+		key  RevocationKey
+	}{
+		{"token ID", RevocationKey{Type: RevokeByTokenID, Value: "token-id"}},
+		{"session ID", RevocationKey{Type: RevokeBySessionID, Value: "session-id"}},
+		{"subject", RevocationKey{Type: RevokeBySubject, Value: "user-id"}},
+		{"client ID", RevocationKey{Type: RevokeByClientID, Value: "client-id"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given
+			revocationList := NewMemoryRevocationList(0)
+			validator := givenRevocationCheckingValidator(revocationList)
+			request := newRequestWithClaims(givenRevocableClaims(time.Now().Add(-time.Minute)))
+			require.NoError(t, validator.ValidateRequest(request))
+
+			// When
+			revocationList.Revoke(testCase.key, time.Now().Add(time.Hour))
+			err := validator.ValidateRequest(request)
+
+			// Then
+			assert.ErrorIs(t, err, ErrTokenRevoked)
+			assert.Equal(t, "revoked", ErrorReason(err))
+		})
+	}
+}
+
+func Test_GivenRevokedToken_WhenTheMiddlewareIsCalled_ThenExpectAnInvalidTokenChallenge(t *testing.T) {
+	// Given
+	revocationList := NewMemoryRevocationList(0)
+	revocationList.Revoke(RevocationKey{Type: RevokeByTokenID, Value: "token-id"}, time.Now().Add(time.Hour))
+	validator := givenRevocationCheckingValidator(revocationList)
+	handler := validator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("the handler must not be called")
+	}))
+
+	// When
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, newRequestWithClaims(givenRevocableClaims(time.Now().Add(-time.Minute))))
+
+	// Then
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(t, `Bearer realm="bitrise-services", error="invalid_token", error_description="the token is revoked"`, recorder.Header().Get("WWW-Authenticate"))
+}
+
+func Test_GivenRevokedSubject_WhenATokenIsIssuedAfterTheRevocation_ThenExpectTheTokenToBeAccepted(t *testing.T) {
+	// Given
+	now := time.Now()
+	revocationList := NewMemoryRevocationList(0)
+	revocationList.clock = func() time.Time { return now.Add(-time.Minute) }
+	revocationList.Revoke(RevocationKey{Type: RevokeBySubject, Value: "user-id"}, now.Add(time.Hour))
+	revocationList.clock = func() time.Time { return now }
+	validator := givenRevocationCheckingValidator(revocationList)
+
+	// When
+	oldTokenErr := validator.ValidateRequest(newRequestWithClaims(givenRevocableClaims(now.Add(-2 * time.Minute))))
+	newTokenErr := validator.ValidateRequest(newRequestWithClaims(givenRevocableClaims(now)))
+
+	// Then
+	assert.ErrorIs(t, oldTokenErr, ErrTokenRevoked)
+	assert.NoError(t, newTokenErr)
+}
+
+func Test_GivenExpiredRevocation_WhenTheListIsCleanedUp_ThenExpectTheRevocationToBeRemoved(t *testing.T) {
+	// Given
+	now := time.Now()
+	revocationList := NewMemoryRevocationList(0)
+	revocationList.clock = func() time.Time { return now }
+	revocationList.Revoke(RevocationKey{Type: RevokeByTokenID, Value: "expired"}, now.Add(time.Minute))
+	revocationList.Revoke(RevocationKey{Type: RevokeByTokenID, Value: "active"}, now.Add(time.Hour))
+
+	// When
+	now = now.Add(time.Minute)
+	_, expiredFound, _ := revocationList.RevokedAt(context.Background(), RevocationKey{Type: RevokeByTokenID, Value: "expired"})
+	revocationList.cleanup()
+
+	// Then
+	assert.False(t, expiredFound)
+	assert.Equal(t, 1, revocationList.count())
+	_, activeFound, _ := revocationList.RevokedAt(context.Background(), RevocationKey{Type: RevokeByTokenID, Value: "active"})
+	assert.True(t, activeFound)
+}
+
+func Test_GivenFailingRevocationStore_WhenTheTokenIsValidated_ThenExpectARevocationCheckError(t *testing.T) {
+	// Given
+	store := revocationStoreFunc(func(context.Context, RevocationKey) (time.Time, bool, error) {
+		return time.Time{}, false, errors.New("connection refused")
+	})
+	validator := givenRevocationCheckingValidator(NewRevocationStoreChecker(store))
+
+	// When
+	err := validator.ValidateRequest(newRequestWithClaims(givenRevocableClaims(time.Now())))
+
+	// Then
+	assert.ErrorIs(t, err, ErrRevocationCheckFailed)
+	assert.NotErrorIs(t, err, ErrTokenRevoked)
+	assert.Equal(t, "revocation_check_failed", ErrorReason(err))
+	assert.Equal(t, bearerError{http.StatusUnauthorized, bearerErrorInvalidToken, "the token could not be verified"}, newBearerError(nil, err))
+}
+
+func Test_GivenRevocationStore_WhenTheTokenIsValidated_ThenExpectItsKeysToBeLookedUp(t *testing.T) {
+	// Given
+	var keys []RevocationKey
+	store := revocationStoreFunc(func(_ context.Context, key RevocationKey) (time.Time, bool, error) {
+		keys = append(keys, key)
+		return time.Time{}, false, nil
+	})
+	claims := givenRevocableClaims(time.Now())
+	delete(claims, "client_id")
+	claims["azp"] = "authorized-party"
+	validator := givenRevocationCheckingValidator(NewRevocationStoreChecker(store))
+
+	// When
+	err := validator.ValidateRequest(newRequestWithClaims(claims))
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, []RevocationKey{
+		{Type: RevokeByTokenID, Value: "token-id"},
+		{Type: RevokeBySessionID, Value: "session-id"},
+		{Type: RevokeBySubject, Value: "user-id"},
+		{Type: RevokeByClientID, Value: "authorized-party"},
+	}, keys)
+}
+
+func Test_GivenCachedToken_WhenItIsRevoked_ThenExpectTheCacheHitToBeRejected(t *testing.T) {
+	// Given
+	revocationList := NewMemoryRevocationList(0)
+	validator := givenRevocationCheckingValidator(revocationList, WithTokenCache())
+	request := newRequestWithClaims(givenRevocableClaims(time.Now().Add(-time.Minute)))
+	require.NoError(t, validator.ValidateRequest(request))
+
+	// When
+	revocationList.Revoke(RevocationKey{Type: RevokeBySessionID, Value: "session-id"}, time.Now().Add(time.Hour))
+	err := validator.ValidateRequest(request)
+
+	// Then
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+}
+
+func Test_GivenMemoryRevocationListWithCleanup_WhenItIsClosedTwice_ThenExpectNoPanic(t *testing.T) {
+	// Given
+	revocationList := NewMemoryRevocationList(time.Millisecond)
+	revocationList.Revoke(RevocationKey{Type: RevokeByTokenID, Value: "token-id"}, time.Now())
+
+	// When
+	assert.Eventually(t, func() bool { return revocationList.count() == 0 }, time.Second, time.Millisecond)
+
+	// Then
+	assert.NoError(t, revocationList.Close())
+	assert.NoError(t, revocationList.Close())
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/labstack/echo"
+)
+
+// ErrNoRoutePolicy is returned by the route policy middlewares when no route of the table matches the request,
+// the default error writers respond with 403 Forbidden to it.
+var ErrNoRoutePolicy = errors.New("no route policy matches the request")
+
+// RoutePolicyTable enforces a route policy table (config.RoutesConfig) with a single middleware: the first route
+// that matches the method and the path of the request decides if the request is public, needs a valid token
+// or needs a valid token with scopes and permissions. Requests that match no route are denied.
+type RoutePolicyTable struct {
+	validator Validator
+	routes    []routePolicy
+}
+
+type routePolicy struct {
+	method   string
+	segments []string
+	public   bool
+	config   config.RouteConfig
+}
+
+// NewRoutePolicyTable validates the route policy table and returns the middlewares that enforce it
+// with the validator.
+func NewRoutePolicyTable(validator Validator, cfg config.RoutesConfig) (*RoutePolicyTable, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	table := &RoutePolicyTable{validator: validator}
+	for _, route := range cfg.Routes {
+		method := strings.ToUpper(route.Method)
+		if method == "" {
+			method = "*"
+		}
+
+		table.routes = append(table.routes, routePolicy{
+			method:   method,
+			segments: pathSegments(route.Path),
+			public:   route.Public,
+			config:   route,
+		})
+	}
+
+	return table, nil
+}
+
+// LoadRoutePolicyTableFromFile loads the route policy table from a YAML or JSON file, see NewRoutePolicyTable.
+func LoadRoutePolicyTableFromFile(validator Validator, path string) (*RoutePolicyTable, error) {
+	cfg, err := config.LoadRoutesConfigFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRoutePolicyTable(validator, cfg)
+}
+
+// Middleware used as http package's middleware, the options are applied to every protected route.
+func (t *RoutePolicyTable) Middleware(next http.Handler, opts ...HTTPMiddlewareOption) http.Handler {
+	opts = withBearerRealm(t.validator, opts)
+	handlerConfig := &HTTPMiddlewareConfig{
+		errorWriter: defaultHTTPErrorWriter,
+	}
+	for _, opt := range opts {
+		opt(handlerConfig)
+	}
+
+	handlers := make([]http.Handler, len(t.routes))
+	for i, route := range t.routes {
+		if route.public {
+			handlers[i] = next
+			continue
+		}
+		routeOpts := append(append([]HTTPMiddlewareOption{}, opts...), route.httpRequirements()...)
+		handlers[i] = httpMiddleware(t.validator.ValidateRequestAndReturnToken, next, routeOpts...)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := t.match(r)
+		if i < 0 {
+			handlerConfig.errorWriter(w, r, ErrNoRoutePolicy)
+			return
+		}
+		handlers[i].ServeHTTP(w, r)
+	})
+}
+
+// EchoMiddlewareFunc can be used with echo.Use, the options are applied to every protected route.
+func (t *RoutePolicyTable) EchoMiddlewareFunc(opts ...EchoMiddlewareOption) echo.MiddlewareFunc {
+	opts = withEchoBearerRealm(t.validator, opts)
+	handlerConfig := &EchoMiddlewareConfig{
+		errorWriter: defaultEchoErrorWriter,
+	}
+	for _, opt := range opts {
+		opt(handlerConfig)
+	}
+
+	middlewares := make([]echo.MiddlewareFunc, len(t.routes))
+	for i, route := range t.routes {
+		if !route.public {
+			routeOpts := append(append([]EchoMiddlewareOption{}, opts...), route.echoRequirements()...)
+			middlewares[i] = echoMiddlewareFunc(t.validator.ValidateRequestAndReturnToken, routeOpts...)
+		}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		handlers := make([]echo.HandlerFunc, len(middlewares))
+		for i, middleware := range middlewares {
+			if middleware == nil {
+				handlers[i] = next
+				continue
+			}
+			handlers[i] = middleware(next)
+		}
+
+		return func(c echo.Context) error {
+			i := t.match(c.Request())
+			if i < 0 {
+				return handlerConfig.errorWriter(c, ErrNoRoutePolicy)
+			}
+			return handlers[i](c)
+		}
+	}
+}
+
+// match returns the index of the first route that matches the request, or -1.
+func (t *RoutePolicyTable) match(r *http.Request) int {
+	method := strings.ToUpper(r.Method)
+	segments, ok := requestPathSegments(r.URL)
+	if !ok {
+		return -1
+	}
+
+	for i, route := range t.routes {
+		if route.method != "*" && route.method != method {
+			continue
+		}
+		if matchSegments(route.segments, segments) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (route routePolicy) httpRequirements() []HTTPMiddlewareOption {
+	var opts []HTTPMiddlewareOption
+	if len(route.config.Scopes) > 0 {
+		opts = append(opts, RequireScopes(route.config.Scopes...))
+	}
+	if len(route.config.AnyScopes) > 0 {
+		opts = append(opts, RequireAnyScope(route.config.AnyScopes...))
+	}
+	for _, permission := range route.config.Permissions {
+		opts = append(opts, RequirePermission(permission.Resource, permission.Scopes...))
+	}
+
+	return opts
+}
+
+func (route routePolicy) echoRequirements() []EchoMiddlewareOption {
+	var opts []EchoMiddlewareOption
+	if len(route.config.Scopes) > 0 {
+		opts = append(opts, EchoRequireScopes(route.config.Scopes...))
+	}
+	if len(route.config.AnyScopes) > 0 {
+		opts = append(opts, EchoRequireAnyScope(route.config.AnyScopes...))
+	}
+	for _, permission := range route.config.Permissions {
+		opts = append(opts, EchoRequirePermission(permission.Resource, permission.Scopes...))
+	}
+
+	return opts
+}
+
+// pathSegments splits the path pattern of a route into segments.
+func pathSegments(p string) []string {
+	trimmed := strings.Trim(p, "/")
+	if trimmed == "" {
+		return nil
+	}
+
+	return strings.Split(trimmed, "/")
+}
+
+// requestPathSegments splits the escaped path of the request into segments and unescapes them one by one,
+// without cleaning the path, so the policy is chosen from the same segments the routers dispatch on:
+// "/admin/..%2Fhealth" has the two segments "admin" and "../health". ok is false if a segment is "." or ".."
+// (escaped or not) or can't be unescaped, such requests match no route.
+func requestPathSegments(u *url.URL) (segments []string, ok bool) {
+	trimmed := strings.Trim(u.EscapedPath(), "/")
+	if trimmed == "" {
+		return nil, true
+	}
+
+	for _, escaped := range strings.Split(trimmed, "/") {
+		segment, err := url.PathUnescape(escaped)
+		if err != nil || segment == "." || segment == ".." {
+			return nil, false
+		}
+		segments = append(segments, segment)
+	}
+
+	return segments, true
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for i, p := range pattern {
+		if p == "**" {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if p == "*" || strings.HasPrefix(p, ":") || (strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}")) {
+			continue
+		}
+		if p != segments[i] {
+			return false
+		}
+	}
+
+	return len(pattern) == len(segments)
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRoutesConfig = `
+routes:
+  - path: /health
+    public: true
+  - method: GET
+    path: /apps/:app_slug
+    scopes: [app:read]
+  - method: POST
+    path: /apps/{app_slug}/builds
+    permissions:
+      - resource: builds
+        scopes: [write]
+  - method: get
+    path: /apps/*/builds/**
+    any_scopes: [build:read, build:write]
+  - path: /me
+`
+
+func Test_RoutePolicyTable(t *testing.T) {
+	testCases := []struct {
+		name               string
+		method             string
+		path               string
+		scope              string
+		withoutToken       bool
+		expectedStatusCode int
+	}{
+		{
+			name:               "Given a public route when the request has no token then expect it to be handled",
+			method:             http.MethodGet,
+			path:               "/health",
+			withoutToken:       true,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Given an authenticated route when the request has a valid token then expect it to be handled",
+			method:             http.MethodDelete,
+			path:               "/me",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Given an authenticated route when the request has no token then expect unauthorized",
+			method:             http.MethodGet,
+			path:               "/me",
+			withoutToken:       true,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Given a route with scopes when the token has them then expect it to be handled",
+			method:             http.MethodGet,
+			path:               "/apps/app-slug",
+			scope:              "app:read",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Given a route with scopes when the token misses them then expect forbidden",
+			method:             http.MethodGet,
+			path:               "/apps/app-slug",
+			scope:              "build:read",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Given a route with permissions when the token misses them then expect forbidden",
+			method:             http.MethodPost,
+			path:               "/apps/app-slug/builds",
+			scope:              "app:read",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Given a route with a trailing wildcard when the token has one of the scopes then expect it to be handled",
+			method:             http.MethodGet,
+			path:               "/apps/app-slug/builds/build-slug/log",
+			scope:              "build:write",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Given a path escaping a public route when it is requested then expect it to be denied",
+			method:             http.MethodGet,
+			path:               "/health/../apps/app-slug",
+			scope:              "build:read",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Given an encoded dot segment in a public route when it is requested then expect it to be denied",
+			method:             http.MethodGet,
+			path:               "/health/%2E%2E/apps/app-slug",
+			withoutToken:       true,
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Given an encoded slash in a route parameter when the request has no token then expect unauthorized",
+			method:             http.MethodGet,
+			path:               "/apps/..%2F..%2Fhealth",
+			withoutToken:       true,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Given no route matches the method when the request has a valid token then expect forbidden",
+			method:             http.MethodPut,
+			path:               "/apps/app-slug",
+			scope:              "app:read",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Given no route matches the path when the request has a valid token then expect forbidden",
+			method:             http.MethodGet,
+			path:               "/admin",
+			scope:              "app:read",
+			expectedStatusCode: http.StatusForbidden,
+		},
+	}
+
+	configPath := filepath.Join(t.TempDir(), "routes.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(testRoutesConfig), 0o600))
+
+	validator := NewValidator(config.NewAudienceConfig(defaultAudience[0]),
+		WithIssuer(defaultIssuer),
+		withSecretProvider(defaultSecretProvider))
+	table, err := LoadRoutePolicyTableFromFile(validator, configPath)
+	require.NoError(t, err)
+
+	handler := table.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	e := echo.New()
+	e.Use(table.EchoMiddlewareFunc())
+	e.Any("/*", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given
+			newRequest := func() *http.Request {
+				request := newRequestWithClaims(jwt.Claims{
+					Issuer:   defaultIssuer,
+					Audience: defaultAudience,
+					Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				}, map[string]interface{}{"scope": testCase.scope})
+				if testCase.withoutToken {
+					request.Header.Del(authorizationHeader)
+				}
+				request.Method = testCase.method
+				request.URL = mustParseURL(t, testCase.path)
+				return request
+			}
+
+			// When
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, newRequest())
+
+			echoRecorder := httptest.NewRecorder()
+			e.ServeHTTP(echoRecorder, newRequest())
+
+			// Then
+			assert.Equal(t, testCase.expectedStatusCode, recorder.Code)
+			assert.Equal(t, testCase.expectedStatusCode, echoRecorder.Code)
+		})
+	}
+}
+
+func Test_GivenEncodedPathTraversal_WhenTheProtectedRouteIsRequestedWithoutToken_ThenExpectItNotToBeHandled(t *testing.T) {
+	// Given
+	table, err := NewRoutePolicyTable(NewValidator(config.NewAudienceConfig(defaultAudience[0]),
+		WithIssuer(defaultIssuer),
+		withSecretProvider(defaultSecretProvider)), config.RoutesConfig{Routes: []config.RouteConfig{
+		{Path: "/health", Public: true},
+		{Path: "/admin/:id", Scopes: []string{"admin"}},
+	}})
+	require.NoError(t, err)
+
+	var handledAdmin bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("GET /admin/{id}", func(w http.ResponseWriter, r *http.Request) { handledAdmin = true })
+	handler := table.Middleware(mux)
+
+	e := echo.New()
+	e.Use(table.EchoMiddlewareFunc())
+	e.GET("/health", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	e.GET("/admin/:id", func(c echo.Context) error {
+		handledAdmin = true
+		return c.NoContent(http.StatusOK)
+	})
+
+	for _, target := range []string{"/admin/..%2F..%2Fhealth", "/admin/%2E%2E%2F%2E%2E%2Fhealth", "/admin/%2e%2e"} {
+		t.Run(target, func(t *testing.T) {
+			handledAdmin = false
+
+			// When
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+
+			echoRecorder := httptest.NewRecorder()
+			e.ServeHTTP(echoRecorder, httptest.NewRequest(http.MethodGet, target, nil))
+
+			// Then
+			assert.False(t, handledAdmin)
+			assert.Contains(t, []int{http.StatusUnauthorized, http.StatusForbidden}, recorder.Code)
+			assert.Contains(t, []int{http.StatusUnauthorized, http.StatusForbidden}, echoRecorder.Code)
+		})
+	}
+}
+
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	return u
+}
+
+func Test_GivenInvalidRoutesConfig_WhenRoutePolicyTableIsCreated_ThenExpectAnError(t *testing.T) {
+	// Given
+	cfg := config.RoutesConfig{Routes: []config.RouteConfig{{Path: "health"}}}
+
+	// When
+	table, err := NewRoutePolicyTable(nil, cfg)
+
+	// Then
+	assert.Nil(t, table)
+	assert.ErrorContains(t, err, "routes[0]: path must start with /")
+}
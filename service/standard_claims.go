@@ -0,0 +1,49 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// StandardClaims are the registered claims of RFC 7519 and the common OIDC / OAuth 2.0 claims of the token.
+type StandardClaims struct {
+	Subject           string           `json:"sub,omitempty"`
+	Issuer            string           `json:"iss,omitempty"`
+	Audience          jwt.Audience     `json:"aud,omitempty"`
+	Expiry            *jwt.NumericDate `json:"exp,omitempty"`
+	NotBefore         *jwt.NumericDate `json:"nbf,omitempty"`
+	IssuedAt          *jwt.NumericDate `json:"iat,omitempty"`
+	ID                string           `json:"jti,omitempty"`
+	AuthorizedParty   string           `json:"azp,omitempty"`
+	SessionID         string           `json:"sid,omitempty"`
+	Scope             string           `json:"scope,omitempty"`
+	PreferredUsername string           `json:"preferred_username,omitempty"`
+}
+
+// Scopes returns the space-separated scopes of the scope claim.
+func (c StandardClaims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// HasScope reports whether the scope claim contains the scope.
+func (c StandardClaims) HasScope(scope string) bool {
+	for _, s := range c.Scopes() {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DecodeClaims unmarshals the verified payload of the token into a value of the caller-defined type T,
+// which is usually a struct with json tags.
+func DecodeClaims[T any](token TokenWithClaims) (T, error) {
+	var claims T
+	if err := token.DecodePayload(&claims); err != nil {
+		return claims, err
+	}
+
+	return claims, nil
+}
@@ -69,6 +69,20 @@ func (testToken testTokenConfig) getTokenString() string {
 	return tokenStr
 }
 
+func (testToken testTokenConfig) getTokenStringWithClaims(claims interface{}) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: testToken.alg, Key: testToken.key}, (&jose.SignerOptions{ExtraHeaders: map[jose.HeaderKey]interface{}{"kid": testToken.kid}}).WithType("JWT"))
+	if err != nil {
+		panic(err)
+	}
+
+	tokenStr, err := jwt.Signed(signer).Claims(claims).Serialize()
+	if err != nil {
+		panic(err)
+	}
+
+	return tokenStr
+}
+
 func (testToken testTokenConfig) newTokenWithClaims(claims interface{}) (*jwt.JSONWebToken, interface{}) {
 	actKey := jose.SigningKey{Algorithm: testToken.alg, Key: testToken.key}
 	signer, err := jose.NewSigner(actKey, (&jose.SignerOptions{ExtraHeaders: map[jose.HeaderKey]interface{}{"kid": testToken.kid}}).WithType("JWT"))
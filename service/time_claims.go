@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// Errors of the time-based claim validation, besides jwt.ErrExpired, jwt.ErrNotValidYet and jwt.ErrIssuedInTheFuture.
+var (
+	// ErrMissingExpiration is returned when the expiration (exp) is required but the token does not have one.
+	ErrMissingExpiration = errors.New("token has no expiration (exp)")
+	// ErrMissingIssuedAt is returned when a maximum token age is set but the token has no issue time (iat).
+	ErrMissingIssuedAt = errors.New("token has no issue time (iat)")
+	// ErrTokenTooOld is returned when the token was issued earlier than the maximum token age.
+	ErrTokenTooOld = errors.New("token is older than the maximum token age (iat)")
+)
+
+// timeClaimsConfig configures the validation of the exp, nbf and iat claims.
+type timeClaimsConfig struct {
+	leeway               time.Duration
+	requireExpiration    bool
+	rejectIssuedInFuture bool
+	maxTokenAge          time.Duration
+	clock                func() time.Time
+}
+
+func defaultTimeClaimsConfig() timeClaimsConfig {
+	return timeClaimsConfig{
+		leeway:               jwt.DefaultLeeway,
+		rejectIssuedInFuture: true,
+		clock:                time.Now,
+	}
+}
+
+// validate checks the time-based claims against the current time of the clock,
+// every check allows the configured leeway to tolerate clock skew.
+func (c timeClaimsConfig) validate(claims registeredClaims) error {
+	now := c.clock()
+
+	if claims.Expiry == nil {
+		if c.requireExpiration {
+			return ErrMissingExpiration
+		}
+	} else if now.Add(-c.leeway).After(claims.Expiry.Time()) {
+		return jwt.ErrExpired
+	}
+
+	if claims.NotBefore != nil && now.Add(c.leeway).Before(claims.NotBefore.Time()) {
+		return jwt.ErrNotValidYet
+	}
+
+	if claims.IssuedAt != nil && c.rejectIssuedInFuture && now.Add(c.leeway).Before(claims.IssuedAt.Time()) {
+		return jwt.ErrIssuedInTheFuture
+	}
+
+	if c.maxTokenAge > 0 {
+		if claims.IssuedAt == nil {
+			return ErrMissingIssuedAt
+		}
+		if now.Sub(claims.IssuedAt.Time()) > c.maxTokenAge+c.leeway {
+			return ErrTokenTooOld
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TimeClaimValidation(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(offset time.Duration) *jwt.NumericDate {
+		return jwt.NewNumericDate(now.Add(offset))
+	}
+
+	testCases := []struct {
+		name          string
+		claims        jwt.Claims
+		opts          []ValidatorOption
+		expectedError error
+	}{
+		{
+			name:   "Given the default leeway when the token expired within the leeway then expect no error",
+			claims: jwt.Claims{Expiry: at(-30 * time.Second)},
+		},
+		{
+			name:          "Given the default leeway when the token expired beyond the leeway then expect an expired error",
+			claims:        jwt.Claims{Expiry: at(-2 * time.Minute)},
+			expectedError: jwt.ErrExpired,
+		},
+		{
+			name:   "Given a longer leeway when the token expired within the leeway then expect no error",
+			claims: jwt.Claims{Expiry: at(-2 * time.Minute)},
+			opts:   []ValidatorOption{WithLeeway(5 * time.Minute)},
+		},
+		{
+			name:          "Given no leeway when the token expired a second ago then expect an expired error",
+			claims:        jwt.Claims{Expiry: at(-time.Second)},
+			opts:          []ValidatorOption{WithLeeway(0)},
+			expectedError: jwt.ErrExpired,
+		},
+		{
+			name:          "Given a token that is not valid yet then expect a not valid yet error",
+			claims:        jwt.Claims{Expiry: at(time.Hour), NotBefore: at(2 * time.Minute)},
+			expectedError: jwt.ErrNotValidYet,
+		},
+		{
+			name:          "Given a token issued in the future then expect an issued in the future error",
+			claims:        jwt.Claims{Expiry: at(time.Hour), IssuedAt: at(2 * time.Minute)},
+			expectedError: jwt.ErrIssuedInTheFuture,
+		},
+		{
+			name:   "Given tokens issued in the future are allowed when the token is issued in the future then expect no error",
+			claims: jwt.Claims{Expiry: at(time.Hour), IssuedAt: at(2 * time.Minute)},
+			opts:   []ValidatorOption{WithRejectIssuedInTheFuture(false)},
+		},
+		{
+			name:   "Given the expiration is not required when the token has no expiration then expect no error",
+			claims: jwt.Claims{},
+		},
+		{
+			name:          "Given the expiration is required when the token has no expiration then expect a missing expiration error",
+			claims:        jwt.Claims{},
+			opts:          []ValidatorOption{WithRequiredExpiration()},
+			expectedError: ErrMissingExpiration,
+		},
+		{
+			name:   "Given a maximum token age when the token is younger then expect no error",
+			claims: jwt.Claims{Expiry: at(time.Hour), IssuedAt: at(-30 * time.Minute)},
+			opts:   []ValidatorOption{WithMaxTokenAge(time.Hour)},
+		},
+		{
+			name:          "Given a maximum token age when the token is older then expect a too old error",
+			claims:        jwt.Claims{Expiry: at(time.Hour), IssuedAt: at(-2 * time.Hour)},
+			opts:          []ValidatorOption{WithMaxTokenAge(time.Hour)},
+			expectedError: ErrTokenTooOld,
+		},
+		{
+			name:          "Given a maximum token age when the token has no issue time then expect a missing issued at error",
+			claims:        jwt.Claims{Expiry: at(time.Hour)},
+			opts:          []ValidatorOption{WithMaxTokenAge(time.Hour)},
+			expectedError: ErrMissingIssuedAt,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given
+			testCase.claims.Issuer = defaultIssuer
+			testCase.claims.Audience = defaultAudience
+			request := newRequestWithClaims(testCase.claims)
+
+			opts := []ValidatorOption{
+				WithIssuer(defaultIssuer),
+				withSecretProvider(defaultSecretProvider),
+				WithClock(func() time.Time { return now }),
+			}
+			validator := NewValidator(config.NewAudienceConfig(defaultAudience[0]), append(opts, testCase.opts...)...)
+
+			// When
+			err := validator.ValidateRequest(request)
+
+			// Then
+			if testCase.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, testCase.expectedError)
+			}
+		})
+	}
+}
+
+// newRequestWithClaims returns a request with a token signed by the default key, the claims are merged into its payload.
+func newRequestWithClaims(claims ...interface{}) *http.Request {
+	testToken := newTestTokenConfig()
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: testToken.alg, Key: testToken.key},
+		(&jose.SignerOptions{ExtraHeaders: map[jose.HeaderKey]interface{}{"kid": testToken.kid}}).WithType("JWT"))
+	if err != nil {
+		panic(err)
+	}
+
+	builder := jwt.Signed(signer)
+	for _, c := range claims {
+		builder = builder.Claims(c)
+	}
+
+	tokenString, err := builder.Serialize()
+	if err != nil {
+		panic(err)
+	}
+
+	request, err := http.NewRequest(defaultRequestMethod, defaultRequestURL, nil)
+	if err != nil {
+		panic(err)
+	}
+	request.Header.Add(authorizationHeader, fmt.Sprintf("%s %s", bearer, tokenString))
+
+	return request
+}
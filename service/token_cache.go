@@ -0,0 +1,223 @@
+package service
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTokenCacheMaxEntries = 1024
+	defaultTokenCacheMaxBytes   = 16 << 20
+	defaultTokenCacheTTL        = 5 * time.Minute
+
+	// tokenCachePayloadFactor estimates the memory kept by the payload of a cached token: the payload itself,
+	// the decoded claims map and the typed claims take about 13 times the size of the JSON payload.
+	tokenCachePayloadFactor = 16
+	// tokenCacheEntryOverhead is the estimated size of an entry besides the raw token and the decoded payload.
+	tokenCacheEntryOverhead = 512
+)
+
+// TokenCacheMetrics records the hits and the misses of the verified-token cache per issuer,
+// it is implemented by metrics.DatadogMetrics.
+type TokenCacheMetrics interface {
+	IncrTokenCacheHitMetric(issuer string)
+	IncrTokenCacheMissMetric(issuer string)
+}
+
+// TokenCacheOption ...
+type TokenCacheOption func(c *tokenCacheConfig)
+
+type tokenCacheConfig struct {
+	maxEntries     int
+	maxBytes       int
+	ttl            time.Duration
+	revocationHook func(token TokenWithClaims) bool
+	metrics        TokenCacheMetrics
+}
+
+// WithCacheMaxEntries sets the maximum number of cached tokens, 1024 by default.
+func WithCacheMaxEntries(maxEntries int) TokenCacheOption {
+	return func(c *tokenCacheConfig) {
+		c.maxEntries = maxEntries
+	}
+}
+
+// WithCacheMaxBytes sets the maximum estimated memory usage of the cached tokens, 16 MiB by default.
+func WithCacheMaxBytes(maxBytes int) TokenCacheOption {
+	return func(c *tokenCacheConfig) {
+		c.maxBytes = maxBytes
+	}
+}
+
+// WithCacheTTL sets how long a token is cached at most, 5 minutes by default. Tokens are never cached
+// beyond their expiration (exp) or their maximum age (see WithMaxTokenAge).
+func WithCacheTTL(ttl time.Duration) TokenCacheOption {
+	return func(c *tokenCacheConfig) {
+		c.ttl = ttl
+	}
+}
+
+// WithCacheRevocationHook sets a function that is called with the cached token on every hit. If it reports
+// the token as revoked, the token is evicted and the request is validated again without the cache.
+func WithCacheRevocationHook(hook func(token TokenWithClaims) bool) TokenCacheOption {
+	return func(c *tokenCacheConfig) {
+		c.revocationHook = hook
+	}
+}
+
+// WithCacheMetrics records the hits and the misses of the cache.
+func WithCacheMetrics(metrics TokenCacheMetrics) TokenCacheOption {
+	return func(c *tokenCacheConfig) {
+		c.metrics = metrics
+	}
+}
+
+// tokenCache is a bounded LRU cache of the verified tokens of a validator, keyed by the SHA-256 hash
+// of the raw token. The cached tokens keep the raw token (it is relayed to downstream services), the payload
+// and its decoded claims, the size of an entry is estimated from them. It is safe for concurrent use.
+type tokenCache struct {
+	config tokenCacheConfig
+	issuer string
+	clock  func() time.Time
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*list.Element
+	lru     *list.List
+	size    int
+}
+
+type tokenCacheEntry struct {
+	key     [sha256.Size]byte
+	token   *tokenWithClaims
+	expires time.Time
+	size    int
+}
+
+func newTokenCache(issuer string, timeClaims timeClaimsConfig, opts []TokenCacheOption) *tokenCache {
+	config := tokenCacheConfig{
+		maxEntries: defaultTokenCacheMaxEntries,
+		maxBytes:   defaultTokenCacheMaxBytes,
+		ttl:        defaultTokenCacheTTL,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	return &tokenCache{
+		config:  config,
+		issuer:  issuer,
+		clock:   timeClaims.clock,
+		maxAge:  timeClaims.maxTokenAge,
+		entries: map[[sha256.Size]byte]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// get returns the cached token, the tokens are immutable, so they are shared by the requests.
+func (c *tokenCache) get(rawToken string) (*tokenWithClaims, bool) {
+	token, ok := c.lookup(rawToken)
+	if ok && c.config.revocationHook != nil && c.config.revocationHook(token) {
+		c.remove(rawToken)
+		token, ok = nil, false
+	}
+
+	if c.config.metrics != nil {
+		if ok {
+			c.config.metrics.IncrTokenCacheHitMetric(c.issuer)
+		} else {
+			c.config.metrics.IncrTokenCacheMissMetric(c.issuer)
+		}
+	}
+
+	return token, ok
+}
+
+func (c *tokenCache) lookup(rawToken string) (*tokenWithClaims, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[sha256.Sum256([]byte(rawToken))]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*tokenCacheEntry)
+	if !c.clock().Before(entry.expires) {
+		c.removeElement(element)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(element)
+
+	return entry.token, true
+}
+
+// add caches the verified token until its expiration, its maximum age or the TTL, whichever comes first.
+func (c *tokenCache) add(rawToken string, token *tokenWithClaims) {
+	now := c.clock()
+	expires := now.Add(c.config.ttl)
+
+	claims := token.registered
+	if claims.Expiry != nil && claims.Expiry.Time().Before(expires) {
+		expires = claims.Expiry.Time()
+	}
+	if c.maxAge > 0 && claims.IssuedAt != nil && claims.IssuedAt.Time().Add(c.maxAge).Before(expires) {
+		expires = claims.IssuedAt.Time().Add(c.maxAge)
+	}
+
+	size := tokenCacheEntrySize(rawToken, token)
+	if !expires.After(now) || size > c.config.maxBytes || c.config.maxEntries <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := sha256.Sum256([]byte(rawToken))
+	if element, ok := c.entries[key]; ok {
+		c.removeElement(element)
+	}
+
+	entry := &tokenCacheEntry{
+		key:     key,
+		token:   token,
+		expires: expires,
+		size:    size,
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.size += size
+
+	for len(c.entries) > c.config.maxEntries || c.size > c.config.maxBytes {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// tokenCacheEntrySize estimates the memory kept alive by a cached token.
+func tokenCacheEntrySize(rawToken string, token *tokenWithClaims) int {
+	return len(rawToken) + tokenCachePayloadFactor*len(token.payload) + tokenCacheEntryOverhead
+}
+
+func (c *tokenCache) remove(rawToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[sha256.Sum256([]byte(rawToken))]; ok {
+		c.removeElement(element)
+	}
+}
+
+func (c *tokenCache) removeElement(element *list.Element) {
+	entry := c.lru.Remove(element).(*tokenCacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
+
+func (c *tokenCache) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
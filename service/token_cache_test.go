@@ -0,0 +1,187 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/bitrise-io/bitrise-oauth/metrics"
+	"github.com/bitrise-io/go-auth0"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ TokenCacheMetrics = &metrics.DatadogMetrics{}
+
+type fakeTokenCacheMetrics struct {
+	hits, misses []string
+}
+
+func (m *fakeTokenCacheMetrics) IncrTokenCacheHitMetric(issuer string) {
+	m.hits = append(m.hits, issuer)
+}
+
+func (m *fakeTokenCacheMetrics) IncrTokenCacheMissMetric(issuer string) {
+	m.misses = append(m.misses, issuer)
+}
+
+// givenCountingSecretProvider returns the default secret provider and the number of its key lookups.
+func givenCountingSecretProvider() (auth0.SecretProvider, *atomic.Int32) {
+	var calls atomic.Int32
+	return auth0.SecretProviderFunc(func(r *http.Request) (interface{}, error) {
+		calls.Add(1)
+		return defaultSecretProvider.GetSecret(r)
+	}), &calls
+}
+
+func givenCachingValidator(secretProvider auth0.SecretProvider, clock func() time.Time, opts ...TokenCacheOption) Validator {
+	return NewValidator(
+		config.NewAudienceConfig(defaultAudience[0]),
+		WithIssuer(defaultIssuer),
+		withSecretProvider(secretProvider),
+		WithClock(clock),
+		WithTokenCache(opts...),
+	)
+}
+
+func Test_GivenValidatorWithTokenCache_WhenTheSameTokenIsValidatedAgain_ThenExpectNoKeyLookup(t *testing.T) {
+	// Given
+	secretProvider, calls := givenCountingSecretProvider()
+	cacheMetrics := &fakeTokenCacheMetrics{}
+	validator := givenCachingValidator(secretProvider, time.Now, WithCacheMetrics(cacheMetrics))
+	request := newTestTokenConfig().newRequest()
+
+	// When
+	_, err := validator.ValidateRequestAndReturnToken(request)
+	require.NoError(t, err)
+	lookups := calls.Load()
+	token, err := validator.ValidateRequestAndReturnToken(request)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, lookups, calls.Load())
+	assert.Equal(t, []string{defaultIssuer}, cacheMetrics.hits)
+	assert.Equal(t, []string{defaultIssuer}, cacheMetrics.misses)
+
+	claims, err := token.StandardClaims()
+	require.NoError(t, err)
+	assert.Equal(t, defaultIssuer, claims.Issuer)
+}
+
+func Test_GivenCachedToken_WhenItExpires_ThenExpectAnExpiredTokenError(t *testing.T) {
+	// Given
+	now := time.Now()
+	tokenConfig := newTestTokenConfig()
+	tokenConfig.expTime = now.Add(time.Minute)
+	validator := givenCachingValidator(defaultSecretProvider, func() time.Time { return now }, WithCacheTTL(time.Hour))
+	request := tokenConfig.newRequest()
+	require.NoError(t, validator.ValidateRequest(request))
+
+	// When
+	now = now.Add(time.Minute + jwt.DefaultLeeway + time.Second)
+	err := validator.ValidateRequest(request)
+
+	// Then
+	assert.ErrorIs(t, err, ErrTokenExpired)
+}
+
+func Test_GivenCachedToken_WhenTheTTLElapses_ThenExpectTheTokenToBeVerifiedAgain(t *testing.T) {
+	// Given
+	now := time.Now()
+	secretProvider, calls := givenCountingSecretProvider()
+	validator := givenCachingValidator(secretProvider, func() time.Time { return now }, WithCacheTTL(time.Second))
+	request := newTestTokenConfig().newRequest()
+	require.NoError(t, validator.ValidateRequest(request))
+	lookups := calls.Load()
+
+	// When
+	now = now.Add(time.Second)
+	err := validator.ValidateRequest(request)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, 2*lookups, calls.Load())
+}
+
+func Test_GivenRevocationHook_WhenTheCachedTokenIsRevoked_ThenExpectTheTokenToBeVerifiedAgain(t *testing.T) {
+	// Given
+	secretProvider, calls := givenCountingSecretProvider()
+	var revoked atomic.Bool
+	validator := givenCachingValidator(secretProvider, time.Now, WithCacheRevocationHook(func(TokenWithClaims) bool {
+		return revoked.Load()
+	}))
+	request := newTestTokenConfig().newRequest()
+	require.NoError(t, validator.ValidateRequest(request))
+	lookups := calls.Load()
+
+	// When
+	revoked.Store(true)
+	err := validator.ValidateRequest(request)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, 2*lookups, calls.Load())
+}
+
+func Test_GivenFullTokenCache_WhenATokenIsAdded_ThenExpectTheLeastRecentlyUsedToBeEvicted(t *testing.T) {
+	// Given
+	cache := newTokenCache(defaultIssuer, defaultTimeClaimsConfig(), []TokenCacheOption{WithCacheMaxEntries(2)})
+	token := givenTokenWithClaims(map[string]interface{}{"exp": time.Now().Add(time.Hour).Unix()})
+	cache.add("first", &token)
+	cache.add("second", &token)
+	_, _ = cache.get("first")
+
+	// When
+	cache.add("third", &token)
+
+	// Then
+	assert.Equal(t, 2, cache.count())
+	_, ok := cache.get("second")
+	assert.False(t, ok)
+	_, ok = cache.get("first")
+	assert.True(t, ok)
+	_, ok = cache.get("third")
+	assert.True(t, ok)
+}
+
+func Test_GivenTokenCacheMemoryLimit_WhenTokensAreAdded_ThenExpectTheLimitToBeKept(t *testing.T) {
+	// Given
+	token := givenTokenWithClaims(map[string]interface{}{})
+	cache := newTokenCache(defaultIssuer, defaultTimeClaimsConfig(),
+		[]TokenCacheOption{WithCacheMaxBytes(2*tokenCacheEntrySize("first", &token) - 1)})
+
+	// When
+	cache.add("first", &token)
+	cache.add("second", &token)
+
+	// Then
+	assert.Equal(t, 1, cache.count())
+	_, ok := cache.get("second")
+	assert.True(t, ok)
+}
+
+func Test_GivenTokenWithManyPermissions_WhenItsCacheEntrySizeIsEstimated_ThenExpectTheDecodedClaimsToBeCounted(t *testing.T) {
+	// Given
+	permissions := make([]permisson, 100)
+	for i := range permissions {
+		permissions[i] = permisson{
+			Rsid:   fmt.Sprintf("resource-id-%d", i),
+			Rsname: fmt.Sprintf("resource-%d", i),
+			Scopes: []string{"read", "write"},
+			Claims: map[string]interface{}{"app_slug": []string{"app-slug"}},
+		}
+	}
+	token := givenTokenWithClaims(map[string]interface{}{
+		"authorization": authorization{Permissions: permissions},
+	})
+
+	// When
+	size := tokenCacheEntrySize("raw-token", &token)
+
+	// Then
+	assert.Greater(t, size, 10*len(token.payload))
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const webSocketProtocolHeader = "Sec-WebSocket-Protocol"
+
+// TokenExtractor extracts the raw (encoded) bearer token from a request. It returns an empty token and no error
+// if the request has no token in its source, and an error if the source is malformed (for example the request
+// has multiple Authorization headers), the validators reject both with ErrMissingToken.
+type TokenExtractor interface {
+	ExtractToken(r *http.Request) (string, error)
+}
+
+// TokenExtractorFunc is an adapter to use ordinary functions as TokenExtractor.
+type TokenExtractorFunc func(r *http.Request) (string, error)
+
+// ExtractToken calls f(r).
+func (f TokenExtractorFunc) ExtractToken(r *http.Request) (string, error) {
+	return f(r)
+}
+
+// AuthorizationHeaderExtractor extracts the token of the Authorization header, it is the default extractor.
+// The header is parsed strictly by RFC 6750: the scheme is case insensitive, but the header must be
+// a single "Bearer <token>" value with a token68 token. Headers with another scheme (e.g. Basic) have no token.
+func AuthorizationHeaderExtractor() TokenExtractor {
+	return TokenExtractorFunc(func(r *http.Request) (string, error) {
+		values := r.Header.Values(authorizationHeader)
+		switch {
+		case len(values) == 0:
+			return "", nil
+		case len(values) > 1:
+			return "", errors.New("multiple Authorization headers")
+		}
+
+		scheme, token, _ := strings.Cut(strings.TrimSpace(values[0]), " ")
+		if !strings.EqualFold(scheme, bearer) {
+			return "", nil
+		}
+
+		token = strings.TrimLeft(token, " ")
+		if !isToken68(token) {
+			return "", errors.New("malformed bearer token in the Authorization header")
+		}
+
+		return token, nil
+	})
+}
+
+// HeaderExtractor extracts the token of a custom header (e.g. X-Access-Token), the header holds the bare token.
+func HeaderExtractor(name string) TokenExtractor {
+	return TokenExtractorFunc(func(r *http.Request) (string, error) {
+		values := r.Header.Values(name)
+		switch {
+		case len(values) == 0:
+			return "", nil
+		case len(values) > 1:
+			return "", fmt.Errorf("multiple %s headers", name)
+		}
+
+		return checkToken68(strings.TrimSpace(values[0]), name+" header")
+	})
+}
+
+// CookieExtractor extracts the token of a cookie, for browser clients that can't set the Authorization header.
+// The cookie should be HttpOnly, Secure and SameSite to protect the token and to prevent CSRF.
+func CookieExtractor(name string) TokenExtractor {
+	return TokenExtractorFunc(func(r *http.Request) (string, error) {
+		cookie, err := r.Cookie(name)
+		if errors.Is(err, http.ErrNoCookie) {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		return checkToken68(cookie.Value, name+" cookie")
+	})
+}
+
+// QueryExtractor extracts the token of a query parameter (e.g. access_token). It is opt-in and should be
+// the last resort, because the URLs, and so the tokens, are often logged by proxies and servers.
+func QueryExtractor(param string) TokenExtractor {
+	return TokenExtractorFunc(func(r *http.Request) (string, error) {
+		values := r.URL.Query()[param]
+		switch {
+		case len(values) == 0:
+			return "", nil
+		case len(values) > 1:
+			return "", fmt.Errorf("multiple %s query parameters", param)
+		}
+
+		return checkToken68(values[0], param+" query parameter")
+	})
+}
+
+// WebSocketProtocolExtractor extracts the token of the Sec-WebSocket-Protocol header, for browser WebSocket clients
+// that can't set other headers: the token is sent as a subprotocol with the prefix, like "bearer.<token>".
+// The handshake response must select one of the other subprotocols of the client, never the token.
+func WebSocketProtocolExtractor(prefix string) TokenExtractor {
+	return TokenExtractorFunc(func(r *http.Request) (string, error) {
+		var tokens []string
+		for _, value := range r.Header.Values(webSocketProtocolHeader) {
+			for _, protocol := range strings.Split(value, ",") {
+				if token, found := strings.CutPrefix(strings.TrimSpace(protocol), prefix); found {
+					tokens = append(tokens, token)
+				}
+			}
+		}
+
+		switch {
+		case len(tokens) == 0:
+			return "", nil
+		case len(tokens) > 1:
+			return "", fmt.Errorf("multiple %q WebSocket subprotocols", prefix)
+		}
+
+		return checkToken68(tokens[0], "WebSocket subprotocol")
+	})
+}
+
+// ChainTokenExtractors returns an extractor that tries the extractors in order and returns the first token found.
+// An error of an extractor stops the chain, so a malformed source is not silently skipped.
+func ChainTokenExtractors(extractors ...TokenExtractor) TokenExtractor {
+	return TokenExtractorFunc(func(r *http.Request) (string, error) {
+		for _, extractor := range extractors {
+			token, err := extractor.ExtractToken(r)
+			if err != nil || token != "" {
+				return token, err
+			}
+		}
+
+		return "", nil
+	})
+}
+
+// extractRawToken extracts the token with the extractor, a missing or malformed token is ErrMissingToken.
+func extractRawToken(extractor TokenExtractor, r *http.Request) (string, error) {
+	token, err := extractor.ExtractToken(r)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrMissingToken, err)
+	}
+	if token == "" {
+		return "", ErrMissingToken
+	}
+
+	return token, nil
+}
+
+func checkToken68(token, source string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	if !isToken68(token) {
+		return "", fmt.Errorf("malformed token in the %s", source)
+	}
+
+	return token, nil
+}
+
+// isToken68 reports whether the token matches the token68 syntax of RFC 7235 (the b64token of RFC 6750):
+// 1*( ALPHA / DIGIT / "-" / "." / "_" / "~" / "+" / "/" ) *"=".
+func isToken68(token string) bool {
+	trimmed := strings.TrimRight(token, "=")
+	if trimmed == "" {
+		return false
+	}
+
+	for _, c := range trimmed {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.ContainsRune("-._~+/", c):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
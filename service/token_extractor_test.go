@@ -0,0 +1,188 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/bitrise-io/bitrise-oauth/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TokenExtractors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		extractor     TokenExtractor
+		request       func(r *http.Request)
+		expectedToken string
+		expectedError string
+	}{
+		{
+			name:          "Authorization header with a lowercase scheme and extra whitespace",
+			extractor:     AuthorizationHeaderExtractor(),
+			request:       func(r *http.Request) { r.Header.Set(authorizationHeader, " bearer   abc.def-ghi_jkl== ") },
+			expectedToken: "abc.def-ghi_jkl==",
+		},
+		{
+			name:      "Authorization header with another scheme",
+			extractor: AuthorizationHeaderExtractor(),
+			request:   func(r *http.Request) { r.Header.Set(authorizationHeader, "Basic dXNlcjpwYXNz") },
+		},
+		{
+			name:          "Authorization header without a token",
+			extractor:     AuthorizationHeaderExtractor(),
+			request:       func(r *http.Request) { r.Header.Set(authorizationHeader, "Bearer ") },
+			expectedError: "malformed bearer token in the Authorization header",
+		},
+		{
+			name:          "Authorization header with a token that is not a token68",
+			extractor:     AuthorizationHeaderExtractor(),
+			request:       func(r *http.Request) { r.Header.Set(authorizationHeader, "Bearer abc def") },
+			expectedError: "malformed bearer token in the Authorization header",
+		},
+		{
+			name:      "Multiple Authorization headers",
+			extractor: AuthorizationHeaderExtractor(),
+			request: func(r *http.Request) {
+				r.Header.Add(authorizationHeader, "Bearer a")
+				r.Header.Add(authorizationHeader, "Bearer b")
+			},
+			expectedError: "multiple Authorization headers",
+		},
+		{
+			name:          "Custom header",
+			extractor:     HeaderExtractor("X-Access-Token"),
+			request:       func(r *http.Request) { r.Header.Set("X-Access-Token", "token") },
+			expectedToken: "token",
+		},
+		{
+			name:          "Cookie",
+			extractor:     CookieExtractor("access_token"),
+			request:       func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "access_token", Value: "token"}) },
+			expectedToken: "token",
+		},
+		{
+			name:      "Missing cookie",
+			extractor: CookieExtractor("access_token"),
+			request:   func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "session", Value: "token"}) },
+		},
+		{
+			name:          "Query parameter",
+			extractor:     QueryExtractor("access_token"),
+			request:       func(r *http.Request) { r.URL.RawQuery = "access_token=token" },
+			expectedToken: "token",
+		},
+		{
+			name:          "Repeated query parameter",
+			extractor:     QueryExtractor("access_token"),
+			request:       func(r *http.Request) { r.URL.RawQuery = "access_token=a&access_token=b" },
+			expectedError: "multiple access_token query parameters",
+		},
+		{
+			name:          "WebSocket subprotocol",
+			extractor:     WebSocketProtocolExtractor("bearer."),
+			request:       func(r *http.Request) { r.Header.Set(webSocketProtocolHeader, "graphql-ws, bearer.abc.def.ghi") },
+			expectedToken: "abc.def.ghi",
+		},
+		{
+			name:      "WebSocket subprotocols without a token",
+			extractor: WebSocketProtocolExtractor("bearer."),
+			request:   func(r *http.Request) { r.Header.Set(webSocketProtocolHeader, "graphql-ws") },
+		},
+		{
+			name:          "Chain returns the first token",
+			extractor:     ChainTokenExtractors(AuthorizationHeaderExtractor(), CookieExtractor("access_token")),
+			request:       func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "access_token", Value: "cookie-token"}) },
+			expectedToken: "cookie-token",
+		},
+		{
+			name:      "Chain stops at a malformed source",
+			extractor: ChainTokenExtractors(AuthorizationHeaderExtractor(), CookieExtractor("access_token")),
+			request: func(r *http.Request) {
+				r.Header.Set(authorizationHeader, "Bearer")
+				r.AddCookie(&http.Cookie{Name: "access_token", Value: "cookie-token"})
+			},
+			expectedError: "malformed bearer token in the Authorization header",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			testCase.request(request)
+
+			// When
+			token, err := testCase.extractor.ExtractToken(request)
+
+			// Then
+			if testCase.expectedError != "" {
+				assert.EqualError(t, err, testCase.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectedToken, token)
+		})
+	}
+}
+
+func Test_GivenValidatorWithCookieExtractor_WhenTheTokenIsInACookie_ThenExpectTheTokenToBeValidated(t *testing.T) {
+	// Given
+	validator := NewValidator(
+		config.NewAudienceConfig(defaultAudience[0]),
+		WithIssuer(defaultIssuer),
+		withSecretProvider(defaultSecretProvider),
+		WithTokenExtractor(CookieExtractor("access_token")),
+	)
+	rawToken := newTestTokenConfig().getTokenString()
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.AddCookie(&http.Cookie{Name: "access_token", Value: rawToken})
+
+	var rawTokenInContext string
+	handler := validator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawTokenInContext, _ = RawTokenFromContext(r.Context())
+	}))
+
+	// When
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	// Then
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, rawToken, rawTokenInContext)
+}
+
+func Test_GivenValidatorWithCookieExtractor_WhenTheTokenIsOnlyInTheAuthorizationHeader_ThenExpectAMissingTokenError(t *testing.T) {
+	// Given
+	validator := NewValidator(
+		config.NewAudienceConfig(defaultAudience[0]),
+		WithIssuer(defaultIssuer),
+		withSecretProvider(defaultSecretProvider),
+		WithTokenExtractor(CookieExtractor("access_token")),
+	)
+
+	// When
+	err := validator.ValidateRequest(newTestTokenConfig().newRequest())
+
+	// Then
+	assert.ErrorIs(t, err, ErrMissingToken)
+}
+
+func Test_GivenRepositoryWithQueryExtractor_WhenTheTokenIsInTheQuery_ThenExpectTheValidatorOfItsIssuer(t *testing.T) {
+	// Given
+	vr, err := NewJwtValidatorRegistry(WithRepositoryTokenExtractor(QueryExtractor("access_token")))
+	require.NoError(t, err)
+	validator := NewValidator(config.NewAudienceConfig("bitrise-api"), WithRealm("bitrise-services"))
+	require.NoError(t, vr.Register(tokenIssuerServiceIssuer, validator))
+	request := httptest.NewRequest(http.MethodGet, "/?access_token="+mocks.RawMockToken, nil)
+
+	// When
+	v, iss, err := vr.GetJwtValidatorForRequest(request)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, tokenIssuerServiceIssuer, iss)
+	assert.Equal(t, validator, v)
+}
@@ -22,6 +22,16 @@ type umaToken struct {
 	Authorization authorization    `json:"authorization,omitempty"`
 }
 
+// registeredClaims are the claims checked by the validator. They are decoded apart from the other typed claims,
+// so an unexpected type of an optional claim (like a list in scope) fails StandardClaims only, not the validation.
+type registeredClaims struct {
+	Issuer    string           `json:"iss,omitempty"`
+	Audience  jwt.Audience     `json:"aud,omitempty"`
+	Expiry    *jwt.NumericDate `json:"exp,omitempty"`
+	NotBefore *jwt.NumericDate `json:"nbf,omitempty"`
+	IssuedAt  *jwt.NumericDate `json:"iat,omitempty"`
+}
+
 type authorization struct {
 	Permissions []permisson `json:"permissions,omitempty"`
 }
@@ -36,38 +46,81 @@ type permisson struct {
 // TokenWithClaims ...
 type TokenWithClaims interface {
 	Payload() (map[string]interface{}, error)
+	StandardClaims() (StandardClaims, error)
+	DecodePayload(v interface{}) error
 	Permissions() ([]interface{}, error)
 	Claim(resourceName string, claim interface{}) error
 	ValidateScopes(scopes []string) error
 	ValidatePermissionScopes(resourceName string, scopes []string) error
 }
 
-// TokenWithClaims is a wrapper over jwt.JSONWebToken to extract the
-// claims easily.
+// tokenWithClaims holds the claims of a validated token. The signature is verified and the payload is decoded
+// once, when the token is created, the claims are then served from immutable parsed structures, so a token
+// is safe for concurrent use and can be shared, e.g. by the verified-token cache.
 type tokenWithClaims struct {
-	key    interface{}
-	token  *jwt.JSONWebToken
-	scopes map[string]bool // lazily initialized map of scopes (keys are the scopes, values are just dummy bools)
+	raw     string
+	payload json.RawMessage
+	claims  map[string]interface{}
+
+	registered        registeredClaims
+	registeredErr     error
+	standardClaims    StandardClaims
+	standardClaimsErr error
+	uma               umaToken
+	umaErr            error
+	scopes            map[string]bool // nil if the token has no scope claim
 }
 
-// Payload returns the  contents of the token.
-func (tokenWithClaim *tokenWithClaims) Payload() (map[string]interface{}, error) {
-	payload := make(map[string]interface{})
-	if err := tokenWithClaim.token.Claims(tokenWithClaim.key, &payload); err != nil {
+// newTokenWithClaimsFromPayload decodes the verified payload. The payload must be a JSON object,
+// the errors of the typed claims are returned by the methods that need them.
+func newTokenWithClaimsFromPayload(payload json.RawMessage, raw string) (*tokenWithClaims, error) {
+	t := &tokenWithClaims{raw: raw, payload: payload}
+	if err := json.Unmarshal(payload, &t.claims); err != nil {
 		return nil, err
 	}
 
-	return payload, nil
+	t.registeredErr = json.Unmarshal(payload, &t.registered)
+	t.standardClaimsErr = json.Unmarshal(payload, &t.standardClaims)
+	t.umaErr = json.Unmarshal(payload, &t.uma)
+
+	if scope, ok := t.claims["scope"].(string); ok {
+		t.scopes = make(map[string]bool)
+		for _, s := range strings.Split(scope, " ") {
+			t.scopes[s] = true
+		}
+	}
+
+	return t, nil
 }
 
-// Permissions returns the persmissions part of the token.
-func (tokenWithClaim *tokenWithClaims) Permissions() ([]interface{}, error) {
-	payload, err := tokenWithClaim.Payload()
-	if err != nil {
-		return nil, err
+// Payload returns the  contents of the token, a copy that can be modified.
+func (tokenWithClaim *tokenWithClaims) Payload() (map[string]interface{}, error) {
+	return copyJSONValue(tokenWithClaim.claims).(map[string]interface{}), nil
+}
+
+// StandardClaims returns the registered and the common OIDC claims of the token.
+func (tokenWithClaim *tokenWithClaims) StandardClaims() (StandardClaims, error) {
+	if tokenWithClaim.standardClaimsErr != nil {
+		return StandardClaims{}, tokenWithClaim.standardClaimsErr
 	}
 
-	authorization, ok := payload[authorizationKey].(map[string]interface{})
+	claims := tokenWithClaim.standardClaims
+	claims.Audience = append(jwt.Audience(nil), claims.Audience...)
+	claims.Expiry = copyNumericDate(claims.Expiry)
+	claims.NotBefore = copyNumericDate(claims.NotBefore)
+	claims.IssuedAt = copyNumericDate(claims.IssuedAt)
+
+	return claims, nil
+}
+
+// DecodePayload unmarshals the contents of the token into v, like json.Unmarshal.
+func (tokenWithClaim *tokenWithClaims) DecodePayload(v interface{}) error {
+	return json.Unmarshal(tokenWithClaim.payload, v)
+}
+
+// Permissions returns the persmissions part of the token.
+func (tokenWithClaim *tokenWithClaims) Permissions() ([]interface{}, error) {
+	authorization, ok := tokenWithClaim.claims[authorizationKey].(map[string]interface{})
 	if !ok {
 		return nil, errors.New("authorization is missing from token")
 	}
@@ -77,17 +130,16 @@ func (tokenWithClaim *tokenWithClaims) Permissions() ([]interface{}, error) {
 		return nil, errors.New("permissions is missing from token")
 	}
 
-	return permissions, nil
+	return copyJSONValue(permissions).([]interface{}), nil
 }
 
 // Claim returns the claim for the provided resource's name.
 func (tokenWithClaim *tokenWithClaims) Claim(resourceName string, claim interface{}) error {
-	token := umaToken{}
-	if err := tokenWithClaim.token.Claims(tokenWithClaim.key, &token); err != nil {
-		return err
+	if tokenWithClaim.umaErr != nil {
+		return tokenWithClaim.umaErr
 	}
 
-	for _, permission := range token.Authorization.Permissions {
+	for _, permission := range tokenWithClaim.uma.Authorization.Permissions {
 		if permission.Rsname == resourceName {
 			// First we have to serialize to json
 			jsonClaims, err := json.Marshal(permission.Claims)
@@ -109,23 +161,8 @@ func (tokenWithClaim *tokenWithClaims) Claim(resourceName string, claim interfac
 
 // ValidateScopes check if the token has ALL the passed scopes in its scope claim - returns an error if any of the scopes is missing
 func (tokenWithClaim *tokenWithClaims) ValidateScopes(scopes []string) error {
-	// initialize scopes map
 	if tokenWithClaim.scopes == nil {
-		claims, err := tokenWithClaim.Payload()
-		if err != nil {
-			return err
-		}
-
-		tokenScopesString, ok := claims["scope"].(string)
-		if !ok {
-			return errors.New("no scope claim in token")
-		}
-		tokenScopesSlice := strings.Split(tokenScopesString, " ")
-
-		tokenWithClaim.scopes = make(map[string]bool)
-		for _, scope := range tokenScopesSlice {
-			tokenWithClaim.scopes[scope] = true
-		}
+		return errors.New("no scope claim in token")
 	}
 
 	for _, scope := range scopes {
@@ -139,12 +176,11 @@ func (tokenWithClaim *tokenWithClaims) ValidateScopes(scopes []string) error {
 
 // ValidatePermissionScopes check if the token has ALL the passed scopes in its permissions scope claim - returns an error if any of the scopes is missing
 func (tokenWithClaim *tokenWithClaims) ValidatePermissionScopes(resourceName string, scopes []string) error {
-	token := umaToken{}
-	if err := tokenWithClaim.token.Claims(tokenWithClaim.key, &token); err != nil {
-		return err
+	if tokenWithClaim.umaErr != nil {
+		return tokenWithClaim.umaErr
 	}
 
-	for _, permission := range token.Authorization.Permissions {
+	for _, permission := range tokenWithClaim.uma.Authorization.Permissions {
 		if permission.Rsname == resourceName {
 			permissionScopes := make(map[string]bool)
 			for _, scope := range permission.Scopes {
@@ -169,3 +205,32 @@ func (tokenWithClaim *tokenWithClaims) ValidatePermissionScopes(resourceName str
 
 	return nil
 }
+
+// copyJSONValue returns a deep copy of a decoded JSON value, so the callers can't modify the claims of the token.
+func copyJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = copyJSONValue(item)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, item := range v {
+			s[i] = copyJSONValue(item)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+func copyNumericDate(date *jwt.NumericDate) *jwt.NumericDate {
+	if date == nil {
+		return nil
+	}
+
+	d := *date
+	return &d
+}
@@ -1,10 +1,16 @@
 package service
 
 import (
+	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/go-jose/go-jose/v4"
 	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -27,6 +33,67 @@ func Test_Payload(t *testing.T) {
 	assert.NotEmpty(t, data["authorization"])
 }
 
+// StandardClaims
+func Test_StandardClaims(t *testing.T) {
+	// Given
+	issuedAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tokenWithClaims := givenTokenWithClaims(map[string]interface{}{
+		"sub":                "user-id",
+		"iss":                defaultIssuer,
+		"aud":                "single-audience",
+		"exp":                issuedAt.Add(time.Hour).Unix(),
+		"iat":                issuedAt.Unix(),
+		"jti":                "token-id",
+		"azp":                "client-id",
+		"sid":                "session-id",
+		"scope":              "app:read  build:write",
+		"preferred_username": "user",
+	})
+
+	// When
+	claims, err := tokenWithClaims.StandardClaims()
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, "user-id", claims.Subject)
+	assert.Equal(t, defaultIssuer, claims.Issuer)
+	assert.Equal(t, jwt.Audience{"single-audience"}, claims.Audience)
+	assert.Equal(t, issuedAt.Add(time.Hour), claims.Expiry.Time().UTC())
+	assert.Equal(t, issuedAt, claims.IssuedAt.Time().UTC())
+	assert.Nil(t, claims.NotBefore)
+	assert.Equal(t, "token-id", claims.ID)
+	assert.Equal(t, "client-id", claims.AuthorizedParty)
+	assert.Equal(t, "session-id", claims.SessionID)
+	assert.Equal(t, "user", claims.PreferredUsername)
+	assert.Equal(t, []string{"app:read", "build:write"}, claims.Scopes())
+	assert.True(t, claims.HasScope("build:write"))
+	assert.False(t, claims.HasScope("build:read"))
+}
+
+// DecodeClaims
+func Test_DecodeClaims(t *testing.T) {
+	// Given
+	type customClaims struct {
+		Subject string   `json:"sub"`
+		Groups  []string `json:"groups"`
+	}
+	tokenWithClaims := givenTokenWithClaims(map[string]interface{}{
+		"sub":    "user-id",
+		"groups": []string{"admins", "developers"},
+	})
+
+	// When
+	claims, err := DecodeClaims[customClaims](&tokenWithClaims)
+	_, errWrongType := DecodeClaims[struct {
+		Groups string `json:"groups"`
+	}](&tokenWithClaims)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, customClaims{Subject: "user-id", Groups: []string{"admins", "developers"}}, claims)
+	assert.Error(t, errWrongType)
+}
+
 // Permissions
 func Test_Permissions(t *testing.T) {
 	// Given
@@ -248,14 +315,89 @@ func Test_ValidatePermissionScopes_WhenAllScopesAreFound_ThenExpectError(t *test
 	require.NoError(t, err)
 }
 
+// Immutability
+func Test_GivenTokenWithClaims_WhenThePayloadIsModified_ThenExpectTheClaimsToBeUnchanged(t *testing.T) {
+	// Given
+	tokenWithClaims := givenTokenWithClaims(givenClaimsWithResource("builds", nil))
+
+	// When
+	payload, err := tokenWithClaims.Payload()
+	require.NoError(t, err)
+	payload["iss"] = "modified"
+	payload[authorizationKey].(map[string]interface{})[permissionsKey] = nil
+
+	permissions, err := tokenWithClaims.Permissions()
+	require.NoError(t, err)
+	permissions[0] = nil
+
+	// Then
+	payload, err = tokenWithClaims.Payload()
+	require.NoError(t, err)
+	assert.Equal(t, defaultIssuer, payload["iss"])
+	permissions, err = tokenWithClaims.Permissions()
+	require.NoError(t, err)
+	assert.NotNil(t, permissions[0])
+	assert.NoError(t, tokenWithClaims.ValidatePermissionScopes("builds", []string{"scope"}))
+}
+
+func Test_GivenTokenWithClaims_WhenItIsUsedConcurrently_ThenExpectNoDataRace(t *testing.T) {
+	// Given
+	tokenWithClaims := givenTokenWithClaims(map[string]interface{}{"scope": "app:read app:write"})
+
+	// When
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, tokenWithClaims.ValidateScopes([]string{"app:read"}))
+			_, err := tokenWithClaims.StandardClaims()
+			assert.NoError(t, err)
+		}()
+	}
+
+	// Then
+	wg.Wait()
+}
+
+func Test_GivenMalformedPermissions_WhenClaimsAreRead_ThenExpectOnlyThePermissionMethodsToFail(t *testing.T) {
+	// Given
+	tokenWithClaims := givenTokenWithClaims(map[string]interface{}{
+		"iss":           defaultIssuer,
+		"authorization": "not-an-object",
+	})
+
+	// When
+	_, payloadErr := tokenWithClaims.Payload()
+	_, standardClaimsErr := tokenWithClaims.StandardClaims()
+	permissionErr := tokenWithClaims.ValidatePermissionScopes("builds", []string{"read"})
+
+	// Then
+	assert.NoError(t, payloadErr)
+	assert.NoError(t, standardClaimsErr)
+	assert.Error(t, permissionErr)
+}
+
 // Helpers
 
 func givenTokenWithClaims(claims interface{}) tokenWithClaims {
 	token, _ := newTestTokenConfig().newTokenWithClaims(claims)
-	return tokenWithClaims{
-		key:   defaultSecret.Public(),
-		token: token,
+	tokenWithClaims, err := newVerifiedTokenWithClaims(token, defaultSecret.Public())
+	if err != nil {
+		panic(err)
 	}
+
+	return *tokenWithClaims
+}
+
+// newVerifiedTokenWithClaims verifies the signature of the token and decodes its payload, like the Validator.
+func newVerifiedTokenWithClaims(token *jwt.JSONWebToken, key interface{}) (*tokenWithClaims, error) {
+	var payload json.RawMessage
+	if err := token.Claims(key, &payload); err != nil {
+		return nil, err
+	}
+
+	return newTokenWithClaimsFromPayload(payload, "")
 }
 
 func givenClaimsWithoutAuthorization() interface{} {
@@ -304,3 +446,98 @@ func givenClaimsWithAuthorization(auth authorization) umaToken {
 		Authorization: auth,
 	}
 }
+
+// Benchmarks
+
+// givenBenchmarkRequest returns a validator and a request with a token of three permissions,
+// signed with the default RS256 key.
+func givenBenchmarkRequest() (Validator, *http.Request) {
+	auth := authorization{}
+	for _, resource := range []string{"apps", "builds", "artifacts"} {
+		auth.Permissions = append(auth.Permissions, permisson{Scopes: []string{"read", "write"}, Rsname: resource})
+	}
+
+	rawToken := newTestTokenConfig().getTokenStringWithClaims(givenClaimsWithAuthorization(auth))
+	validator := NewValidator(
+		config.NewAudienceConfig(defaultAudience[0]),
+		WithIssuer(defaultIssuer),
+		withSecretProvider(defaultSecretProvider),
+	)
+	request := httptest.NewRequest(defaultRequestMethod, defaultRequestURL, nil)
+	request.Header.Set(authorizationHeader, bearer+" "+rawToken)
+
+	return validator, request
+}
+
+// Benchmark_VerifyOnEveryClaimAccess is the cost of validating a request and of a handler that reads the payload
+// and checks three permissions, when every claim access verifies the signature and decodes the payload again,
+// as the tokens did before.
+func Benchmark_VerifyOnEveryClaimAccess(b *testing.B) {
+	validator, request := givenBenchmarkRequest()
+	key := defaultSecret.Public()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := validator.ValidateRequestAndReturnToken(request); err != nil {
+			b.Fatal(err)
+		}
+
+		token, err := parseRequestToken(request, []jose.SignatureAlgorithm{jose.RS256})
+		if err != nil {
+			b.Fatal(err)
+		}
+		payload := map[string]interface{}{}
+		if err := token.Claims(key, &payload); err != nil {
+			b.Fatal(err)
+		}
+		for j := 0; j < 3; j++ {
+			uma := umaToken{}
+			if err := token.Claims(key, &uma); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// Benchmark_ValidateRequestAndAccessClaims is the cost of the same request: the validation verifies
+// the signature and decodes the payload once, the claim accesses of the handler use the decoded claims.
+func Benchmark_ValidateRequestAndAccessClaims(b *testing.B) {
+	validator, request := givenBenchmarkRequest()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tokenWithClaims, err := validator.ValidateRequestAndReturnToken(request)
+		if err != nil {
+			b.Fatal(err)
+		}
+		benchmarkClaimAccess(b, tokenWithClaims)
+	}
+}
+
+// Benchmark_ClaimAccess is the cost of the claim accesses of the handler on a validated token.
+func Benchmark_ClaimAccess(b *testing.B) {
+	validator, request := givenBenchmarkRequest()
+	tokenWithClaims, err := validator.ValidateRequestAndReturnToken(request)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchmarkClaimAccess(b, tokenWithClaims)
+	}
+}
+
+func benchmarkClaimAccess(b *testing.B, tokenWithClaims TokenWithClaims) {
+	if _, err := tokenWithClaims.Payload(); err != nil {
+		b.Fatal(err)
+	}
+	for _, resource := range []string{"apps", "builds", "artifacts"} {
+		if err := permissionRequirement(resource, []string{"read"})(tokenWithClaims); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"github.com/go-jose/go-jose/v4/jwt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "github.com/bitrise-io/bitrise-oauth/service"
+
+	validateRequestSpanName = "oauth.validate_request"
+	verifyTokenSpanName     = "oauth.verify_token"
+	keyLookupSpanName       = "oauth.key_lookup"
+	audienceCheckSpanName   = "oauth.audience_check"
+	revocationCheckSpanName = "oauth.revocation_check"
+
+	issuerAttributeKey    = attribute.Key("oauth.issuer")
+	kidAttributeKey       = attribute.Key("oauth.kid")
+	audienceAttributeKey  = attribute.Key("oauth.audience")
+	resultAttributeKey    = attribute.Key("oauth.result")
+	cacheHitAttributeKey  = attribute.Key("oauth.cache_hit")
+	errorTypeAttributeKey = attribute.Key("error.type")
+
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+func recordResult(span trace.Span, err error) {
+	if err == nil {
+		span.SetAttributes(resultAttributeKey.String(resultSuccess))
+		return
+	}
+
+	span.SetAttributes(
+		resultAttributeKey.String(resultFailure),
+		errorTypeAttributeKey.String(ErrorReason(err)),
+	)
+	span.SetStatus(codes.Error, ErrorReason(err))
+}
+
+func keyID(token *jwt.JSONWebToken) string {
+	if token == nil || len(token.Headers) == 0 {
+		return ""
+	}
+
+	return token.Headers[0].KeyID
+}
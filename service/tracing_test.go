@@ -0,0 +1,71 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_GivenTracerProvider_WhenRequestIsValidated_ThenExpectTheValidationStepsToBeTraced(t *testing.T) {
+	// Given
+	recorder := tracetest.NewSpanRecorder()
+	validator := NewValidator(
+		config.NewAudienceConfig(defaultAudience[0]),
+		WithIssuer(defaultIssuer),
+		withSecretProvider(defaultSecretProvider),
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))),
+	)
+
+	// When
+	err := validator.ValidateRequest(newTestTokenConfig().newRequest())
+
+	// Then
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 4)
+	assert.Equal(t, keyLookupSpanName, spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), kidAttributeKey.String(defaultKid))
+	assert.Equal(t, verifyTokenSpanName, spans[1].Name())
+	assert.Contains(t, spans[1].Attributes(), kidAttributeKey.String(defaultKid))
+	assert.Equal(t, audienceCheckSpanName, spans[2].Name())
+	assert.Contains(t, spans[2].Attributes(), audienceAttributeKey.StringSlice(defaultAudience))
+	assert.Equal(t, validateRequestSpanName, spans[3].Name())
+	assert.Equal(t, trace.SpanKindInternal, spans[3].SpanKind())
+	assert.Contains(t, spans[3].Attributes(), issuerAttributeKey.String(defaultIssuer))
+	assert.Contains(t, spans[3].Attributes(), resultAttributeKey.String(resultSuccess))
+
+	assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	for _, span := range spans[1:3] {
+		assert.Equal(t, spans[3].SpanContext().SpanID(), span.Parent().SpanID())
+	}
+}
+
+func Test_GivenTracerProvider_WhenAudienceIsInvalid_ThenExpectTheErrorClassToBeRecorded(t *testing.T) {
+	// Given
+	recorder := tracetest.NewSpanRecorder()
+	validator := NewValidator(
+		config.NewAudienceConfig("other-audience"),
+		WithIssuer(defaultIssuer),
+		withSecretProvider(defaultSecretProvider),
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))),
+	)
+
+	// When
+	err := validator.ValidateRequest(newTestTokenConfig().newRequest())
+
+	// Then
+	assert.ErrorIs(t, err, jwt.ErrInvalidAudience)
+	assert.ErrorIs(t, err, ErrAudienceMismatch)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 4)
+	assert.Contains(t, spans[3].Attributes(), resultAttributeKey.String(resultFailure))
+	assert.Contains(t, spans[3].Attributes(), errorTypeAttributeKey.String("invalid_audience"))
+}
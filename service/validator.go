@@ -1,6 +1,7 @@
 package service
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
@@ -8,13 +9,16 @@ import (
 	"github.com/bitrise-io/bitrise-oauth/config"
 	"github.com/bitrise-io/go-auth0"
 	"github.com/labstack/echo"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 
 	"github.com/go-jose/go-jose/v4"
 	"github.com/go-jose/go-jose/v4/jwt"
 )
 
+// jwtValidator verifies the signature of the token of the request and returns its verified payload.
 type jwtValidator interface {
-	ValidateRequest(r *http.Request) (*jwt.JSONWebToken, error)
+	ValidateRequest(r *http.Request) (*jwt.JSONWebToken, json.RawMessage, error)
 }
 
 // Validator gives multiple solution to validate the access token received in the request headers using Oauth2.0
@@ -28,27 +32,36 @@ type Validator interface {
 
 // ValidatorConfig ...
 type ValidatorConfig struct {
-	jwtValidator       jwtValidator
-	baseURL            string
-	realm              string
-	keyCacher          auth0.KeyCacher
-	signatureAlgorithm jose.SignatureAlgorithm
-	timeout            time.Duration
-	audience           config.AudienceConfig
-	issuer             string
-	secretProvider     auth0.SecretProvider
-	jwksURL            string
+	jwtValidator        jwtValidator
+	baseURL             string
+	realm               string
+	keyCacher           auth0.KeyCacher
+	signatureAlgorithms []jose.SignatureAlgorithm
+	timeout             time.Duration
+	audience            config.AudienceConfig
+	issuer              string
+	secretProvider      auth0.SecretProvider
+	jwksURL             string
+	tracer              trace.Tracer
+	timeClaims          timeClaimsConfig
+	tokenExtractor      TokenExtractor
+	revocationChecker   RevocationChecker
+	tokenCacheOptions   []TokenCacheOption
+	tokenCache          *tokenCache
 }
 
 // NewValidator returns the prepared JWK model. All input arguments are optional.
 func NewValidator(audienceConfig config.AudienceConfig, opts ...ValidatorOption) Validator {
 	serviceValidator := &ValidatorConfig{
-		baseURL:            config.BaseURL,
-		realm:              config.Realm,
-		keyCacher:          auth0.NewMemoryKeyCacher(2*time.Hour, 5),
-		signatureAlgorithm: jose.RS256,
-		timeout:            30 * time.Second,
-		audience:           audienceConfig,
+		baseURL:             config.BaseURL,
+		realm:               config.Realm,
+		keyCacher:           auth0.NewMemoryKeyCacher(2*time.Hour, 5),
+		signatureAlgorithms: []jose.SignatureAlgorithm{jose.RS256},
+		timeout:             30 * time.Second,
+		audience:            audienceConfig,
+		tracer:              noop.NewTracerProvider().Tracer(tracerName),
+		timeClaims:          defaultTimeClaimsConfig(),
+		tokenExtractor:      AuthorizationHeaderExtractor(),
 	}
 
 	for _, opt := range opts {
@@ -67,6 +80,10 @@ func NewValidator(audienceConfig config.AudienceConfig, opts ...ValidatorOption)
 		serviceValidator.jwtValidator = createDefaultJWTValidator(serviceValidator)
 	}
 
+	if serviceValidator.tokenCacheOptions != nil {
+		serviceValidator.tokenCache = newTokenCache(serviceValidator.issuer, serviceValidator.timeClaims, serviceValidator.tokenCacheOptions)
+	}
+
 	return serviceValidator
 }
 
@@ -81,12 +98,35 @@ func createDefaultSecretProvider(validatorConfig *ValidatorConfig) auth0.SecretP
 		Client: &http.Client{Timeout: validatorConfig.timeout},
 	}
 
-	return auth0.NewJWKClientWithCache(secretProvderClientOptions, nil, validatorConfig.keyCacher)
+	// the default extractor of the JWK client can't parse every accepted algorithm (e.g. ES256 or EdDSA)
+	extractor := auth0.RequestTokenExtractorFunc(func(r *http.Request) (*jwt.JSONWebToken, error) {
+		return parseRequestToken(r, parseableAlgorithms)
+	})
+
+	return jwksClientSecretProvider{auth0.NewJWKClientWithCache(secretProvderClientOptions, extractor, validatorConfig.keyCacher)}
+}
+
+// jwksClientSecretProvider marks the fetch errors of the JWK client with ErrJWKSUnavailable.
+type jwksClientSecretProvider struct {
+	client auth0.SecretProvider
+}
+
+// GetSecret ...
+func (p jwksClientSecretProvider) GetSecret(r *http.Request) (interface{}, error) {
+	key, err := p.client.GetSecret(r)
+	if err != nil && validationErrorKind(err) == ErrJWKSUnavailable {
+		return nil, fmt.Errorf("%w: %w", ErrJWKSUnavailable, err)
+	}
+
+	return key, err
 }
 
 func createDefaultJWTValidator(validatorConfig *ValidatorConfig) jwtValidator {
-	configuration := auth0.NewConfiguration(validatorConfig.secretProvider, []string{}, validatorConfig.issuer, validatorConfig.signatureAlgorithm)
-	return auth0.NewValidator(configuration, nil)
+	return signedTokenValidator{
+		secretProvider: validatorConfig.secretProvider,
+		algorithms:     validatorConfig.signatureAlgorithms,
+		tracer:         validatorConfig.tracer,
+	}
 }
 
 func (sv ValidatorConfig) realmURL() string {
@@ -99,104 +139,130 @@ func (sv ValidatorConfig) defaultJWKSURL() string {
 
 // ValidateRequest to validate if the request is authenticated and has active token.
 func (sv ValidatorConfig) ValidateRequest(r *http.Request) error {
-	token, err := sv.jwtValidator.ValidateRequest(r)
+	_, err := sv.ValidateRequestAndReturnToken(r)
+	return err
+}
+
+// ValidateRequestAndReturnToken ...
+func (sv ValidatorConfig) ValidateRequestAndReturnToken(r *http.Request) (TokenWithClaims, error) {
+	ctx, span := sv.tracer.Start(r.Context(), validateRequestSpanName,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(issuerAttributeKey.String(sv.issuer)))
+	defer span.End()
+
+	tokenWithClaims, err := sv.validateRequest(r.WithContext(ctx))
+	err = newValidationError(err)
+	recordResult(span, err)
+
+	return tokenWithClaims, err
+}
+
+func (sv ValidatorConfig) validateRequest(r *http.Request) (TokenWithClaims, error) {
+	// an empty token is stored too, so the token is never read from another source; it is rejected when parsed
+	rawToken, err := sv.tokenExtractor.ExtractToken(r)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("%w: %w", ErrMissingToken, err)
 	}
+	r = requestWithExtractedToken(r, rawToken)
 
-	if token == nil {
-		return fmt.Errorf("no token found in the request")
+	tokenWithClaims, err := sv.verifiedToken(r, rawToken)
+	if err != nil {
+		return nil, err
 	}
 
-	key, err := sv.secretProvider.GetSecret(r)
-	if err != nil {
-		return err
+	if sv.revocationChecker != nil {
+		ctx, revocationSpan := sv.tracer.Start(r.Context(), revocationCheckSpanName)
+		err = checkRevocation(ctx, sv.revocationChecker, tokenWithClaims)
+		recordResult(revocationSpan, err)
+		revocationSpan.End()
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	tokenWithClaims := &tokenWithClaims{
-		key:   key,
-		token: token,
+	return tokenWithClaims, nil
+}
+
+// verifiedToken returns the cached token, or verifies the token of the request and caches it.
+func (sv ValidatorConfig) verifiedToken(r *http.Request, rawToken string) (*tokenWithClaims, error) {
+	ctx := r.Context()
+
+	if sv.tokenCache != nil {
+		token, ok := sv.tokenCache.get(rawToken)
+		trace.SpanFromContext(ctx).SetAttributes(cacheHitAttributeKey.Bool(ok))
+		if ok {
+			return token, nil
+		}
 	}
 
-	err = sv.validateAudiences(*tokenWithClaims, sv.audience.All())
+	verifyCtx, verifySpan := sv.tracer.Start(ctx, verifyTokenSpanName)
+	tokenWithClaims, kid, err := sv.verifyToken(r.WithContext(verifyCtx), rawToken)
+	verifySpan.SetAttributes(kidAttributeKey.String(kid))
+	recordResult(verifySpan, err)
+	verifySpan.End()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return err
-}
-
-// ValidateRequestAndReturnToken ...
-func (sv ValidatorConfig) ValidateRequestAndReturnToken(r *http.Request) (TokenWithClaims, error) {
-	token, err := sv.jwtValidator.ValidateRequest(r)
+	_, audienceSpan := sv.tracer.Start(ctx, audienceCheckSpanName, trace.WithAttributes(audienceAttributeKey.StringSlice(sv.audience.All())))
+	err = sv.validateAudiences(tokenWithClaims)
+	recordResult(audienceSpan, err)
+	audienceSpan.End()
 	if err != nil {
 		return nil, err
 	}
 
-	if token == nil {
-		return nil, fmt.Errorf("no token found in the request")
+	if sv.tokenCache != nil {
+		sv.tokenCache.add(rawToken, tokenWithClaims)
 	}
 
-	key, err := sv.secretProvider.GetSecret(r)
+	return tokenWithClaims, nil
+}
+
+// verifyToken verifies the signature of the token once, decodes the verified payload once,
+// and checks the registered claims of the decoded payload. It returns the key ID of the token for tracing.
+func (sv ValidatorConfig) verifyToken(r *http.Request, rawToken string) (*tokenWithClaims, string, error) {
+	token, payload, err := sv.jwtValidator.ValidateRequest(r)
+	kid := keyID(token)
 	if err != nil {
-		return nil, err
+		return nil, kid, err
 	}
 
-	tokenWithClaims := &tokenWithClaims{
-		key:   key,
-		token: token,
+	if token == nil {
+		return nil, kid, ErrMissingToken
 	}
 
-	err = sv.validateAudiences(*tokenWithClaims, sv.audience.All())
+	tokenWithClaims, err := newTokenWithClaimsFromPayload(payload, rawToken)
 	if err != nil {
-		return nil, err
+		return nil, kid, fmt.Errorf("%w: %w", ErrMalformedToken, err)
 	}
 
-	return tokenWithClaims, nil
+	return tokenWithClaims, kid, sv.validateRegisteredClaims(tokenWithClaims)
 }
 
-// ValidateAudiences ...
-func (sv ValidatorConfig) validateAudiences(tokenWithClaims tokenWithClaims, audiences []string) error {
-	payload, err := tokenWithClaims.Payload()
-	if err != nil {
-		return err
+// validateRegisteredClaims checks the issuer and the time-based claims of the decoded payload.
+func (sv ValidatorConfig) validateRegisteredClaims(tokenWithClaims *tokenWithClaims) error {
+	if tokenWithClaims.registeredErr != nil {
+		return fmt.Errorf("%w: %w", ErrMalformedToken, tokenWithClaims.registeredErr)
 	}
 
-	var audiencesInToken []string
-
-	switch aud := payload["aud"].(type) {
-	default:
-		panic("unexpected type for audience")
-	case nil:
-		audiencesInToken = []string{}
-	case string:
-		audiencesInToken = []string{aud}
-	case []interface{}:
-		audiencesInToken = make([]string, len(aud))
-		for i, a := range aud {
-			var ok bool
-			audiencesInToken[i], ok = a.(string)
-			if !ok {
-				panic("type assertion failed - string was expected for audience")
-			}
-		}
+	claims := tokenWithClaims.registered
+	if sv.issuer != "" && claims.Issuer != sv.issuer {
+		return jwt.ErrInvalidIssuer
 	}
 
-	if len(sv.audience.All()) > 0 && len(audiencesInToken) == 0 {
-		return jwt.ErrInvalidAudience
-	}
+	return sv.timeClaims.validate(claims)
+}
 
-	if len(sv.audience.All()) > 0 {
-		found := false
-		for _, aud := range sv.audience.All() {
-			if !found && contains(audiencesInToken, aud) {
-				found = true
-			}
-		}
+// validateAudiences checks the audiences of the token with the match mode of the audience config,
+// a malformed "aud" claim (neither a string nor a list of strings) is an invalid audience.
+func (sv ValidatorConfig) validateAudiences(tokenWithClaims *tokenWithClaims) error {
+	if tokenWithClaims.registeredErr != nil {
+		return fmt.Errorf("%w: %w", jwt.ErrInvalidAudience, tokenWithClaims.registeredErr)
+	}
 
-		if !found {
-			return jwt.ErrInvalidAudience
-		}
+	if !sv.audience.Match(tokenWithClaims.registered.Audience) {
+		return jwt.ErrInvalidAudience
 	}
 
 	return nil
@@ -214,65 +280,52 @@ func contains(array []string, element string) bool {
 
 // Middleware used as http package's middleware, in http.Handle.
 // Calls out to ValidateRequest and returns http.Status Unauthorized with body: invalid token if the token is not active.
+// The default error writer sends the realm of the validator in the WWW-Authenticate challenges.
 func (sv ValidatorConfig) Middleware(next http.Handler, opts ...HTTPMiddlewareOption) http.Handler {
-	handlerConfig := &HTTPMiddlewareConfig{
-		errorWriter: defaultHTTPErrorWriter,
-	}
-
-	for _, opt := range opts {
-		opt(handlerConfig)
-	}
-
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token, err := sv.ValidateRequestAndReturnToken(r)
-		if err != nil {
-			handlerConfig.errorWriter(w, r, err)
-			return
-		}
-		if handlerConfig.tokenHandler != nil {
-			handlerConfig.tokenHandler(w, r, token)
-		}
-		next.ServeHTTP(w, r)
-	})
+	return httpMiddleware(sv.ValidateRequestAndReturnToken, next, withBearerRealm(sv, opts)...)
 }
 
 // EchoMiddlewareFunc can be used with echo.Use.
 // Calls out to ValidateRequest and returns an error for echo.
 func (sv ValidatorConfig) EchoMiddlewareFunc(opts ...EchoMiddlewareOption) echo.MiddlewareFunc {
-	handlerConfig := &EchoMiddlewareConfig{
-		errorWriter: defaultEchoErrorWriter,
-	}
-
-	for _, opt := range opts {
-		opt(handlerConfig)
-	}
-
-	return func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
-			if err := sv.ValidateRequest(c.Request()); err != nil {
-				return handlerConfig.errorWriter(c, err)
-			}
-			return next(c)
-		}
-	}
+	return echoMiddlewareFunc(sv.ValidateRequestAndReturnToken, withEchoBearerRealm(sv, opts)...)
 }
 
 // HandlerFunc used with http.HandleFunc.
 // Calls out to ValidateRequest and returns http.Status Unauthorized with body: invalid token if the token is not active.
 func (sv ValidatorConfig) HandlerFunc(hf http.HandlerFunc, opts ...HTTPMiddlewareOption) http.HandlerFunc {
-	handlerConfig := &HTTPMiddlewareConfig{
-		errorWriter: defaultHTTPErrorWriter,
+	return httpHandlerFunc(sv.ValidateRequestAndReturnToken, hf, withBearerRealm(sv, opts)...)
+}
+
+func (sv ValidatorConfig) bearerRealm() string {
+	return sv.realm
+}
+
+// NewValidatorFromConfig validates the configuration and returns the prepared JWK model.
+// The options are applied after the ones derived from the configuration, so they can override them.
+func NewValidatorFromConfig(cfg config.ValidatorConfig, opts ...ValidatorOption) (Validator, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
 
-	for _, opt := range opts {
-		opt(handlerConfig)
+	cfgOpts := []ValidatorOption{WithBaseURL(cfg.BaseURL), WithRealm(cfg.Realm)}
+	if cfg.Issuer != "" {
+		cfgOpts = append(cfgOpts, WithIssuer(cfg.Issuer))
+	}
+	if cfg.JWKSURL != "" {
+		cfgOpts = append(cfgOpts, WithJWKSUrl(cfg.JWKSURL))
+	}
+	if cfg.Timeout > 0 {
+		cfgOpts = append(cfgOpts, WithTimeout(cfg.Timeout))
 	}
 
-	return func(w http.ResponseWriter, r *http.Request) {
-		if err := sv.ValidateRequest(r); err != nil {
-			handlerConfig.errorWriter(w, r, err)
-			return
-		}
-		hf(w, r)
+	if algs := cfg.SignatureAlgorithms(); len(algs) > 0 {
+		cfgOpts = append(cfgOpts, WithSignatureAlgorithms(algs...))
 	}
+
+	if cfg.Leeway != nil {
+		cfgOpts = append(cfgOpts, WithLeeway(*cfg.Leeway))
+	}
+
+	return NewValidator(cfg.AudienceConfig(), append(cfgOpts, opts...)...), nil
 }
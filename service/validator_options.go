@@ -7,6 +7,7 @@ import (
 	"github.com/bitrise-io/go-auth0"
 
 	"github.com/go-jose/go-jose/v4"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ValidatorOption ...
@@ -28,8 +29,15 @@ func WithTimeout(timeout time.Duration) ValidatorOption {
 
 // WithSignatureAlgorithm ...
 func WithSignatureAlgorithm(sa jose.SignatureAlgorithm) ValidatorOption {
+	return WithSignatureAlgorithms(sa)
+}
+
+// WithSignatureAlgorithms sets the accepted signature algorithms (RS256 by default), so the signing algorithm
+// of the authorization server can be changed without a flag-day. The algorithm of a token must also match
+// the "alg" and the key type of its JWK, "none" is never accepted, and neither is HMAC with a public key.
+func WithSignatureAlgorithms(algs ...jose.SignatureAlgorithm) ValidatorOption {
 	return func(c *ValidatorConfig) {
-		c.signatureAlgorithm = sa
+		c.signatureAlgorithms = append([]jose.SignatureAlgorithm(nil), algs...)
 	}
 }
 
@@ -61,6 +69,111 @@ func WithJWKSUrl(url string) ValidatorOption {
 	}
 }
 
+// WithJWKSManager verifies the tokens with the keys of the JWKS manager, instead of fetching them on demand.
+func WithJWKSManager(manager *JWKSManager) ValidatorOption {
+	return func(c *ValidatorConfig) {
+		c.secretProvider = manager
+	}
+}
+
+// WithKeySet verifies the tokens with the keys of the key set, the key is selected by the key ID (kid) of the token.
+func WithKeySet(keySet jose.JSONWebKeySet) ValidatorOption {
+	return func(c *ValidatorConfig) {
+		c.secretProvider = keySetSecretProvider{source: staticKeySource(keySet)}
+	}
+}
+
+// WithJWKSFile verifies the tokens with the keys of a JWKS JSON file. If reloadInterval is positive,
+// the file is checked for changes at most once per interval, so rotated keys are picked up without a restart.
+// If the file can't be read, the validation fails with the error of the file until it is fixed.
+func WithJWKSFile(path string, reloadInterval time.Duration) ValidatorOption {
+	return func(c *ValidatorConfig) {
+		c.secretProvider = keySetSecretProvider{source: newFileKeySource(path, reloadInterval, parseJWKS)}
+	}
+}
+
+// WithPEMFile verifies the tokens with the PEM encoded public keys or certificates of a file, it is reloaded
+// like the file of WithJWKSFile. PEM keys have no key ID: a single key is used for every token, otherwise
+// the key ID of the token must be the RFC 7638 thumbprint (SHA-256, base64url) of the key.
+func WithPEMFile(path string, reloadInterval time.Duration) ValidatorOption {
+	return func(c *ValidatorConfig) {
+		c.secretProvider = keySetSecretProvider{
+			source:            newFileKeySource(path, reloadInterval, parsePEMKeys),
+			singleKeyFallback: true,
+		}
+	}
+}
+
+// WithLeeway sets the tolerated clock skew of the exp, nbf and iat checks, one minute by default.
+func WithLeeway(leeway time.Duration) ValidatorOption {
+	return func(c *ValidatorConfig) {
+		c.timeClaims.leeway = leeway
+	}
+}
+
+// WithRequiredExpiration rejects the tokens without expiration (exp) with ErrMissingExpiration.
+func WithRequiredExpiration() ValidatorOption {
+	return func(c *ValidatorConfig) {
+		c.timeClaims.requireExpiration = true
+	}
+}
+
+// WithRejectIssuedInTheFuture sets whether the tokens issued in the future (iat, beyond the leeway) are rejected
+// with jwt.ErrIssuedInTheFuture, they are rejected by default.
+func WithRejectIssuedInTheFuture(reject bool) ValidatorOption {
+	return func(c *ValidatorConfig) {
+		c.timeClaims.rejectIssuedInFuture = reject
+	}
+}
+
+// WithMaxTokenAge rejects the tokens issued (iat) earlier than the maximum age with ErrTokenTooOld,
+// regardless of their expiration. Tokens without iat are rejected with ErrMissingIssuedAt.
+func WithMaxTokenAge(maxAge time.Duration) ValidatorOption {
+	return func(c *ValidatorConfig) {
+		c.timeClaims.maxTokenAge = maxAge
+	}
+}
+
+// WithClock sets the source of the current time of the time-based claim validation, e.g. for tests.
+func WithClock(clock func() time.Time) ValidatorOption {
+	return func(c *ValidatorConfig) {
+		c.timeClaims.clock = clock
+	}
+}
+
+// WithTracerProvider enables OpenTelemetry tracing of the request validation using the given provider.
+func WithTracerProvider(tp trace.TracerProvider) ValidatorOption {
+	return func(c *ValidatorConfig) {
+		c.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// WithTokenExtractor sets where the token is read from, the Authorization header (AuthorizationHeaderExtractor)
+// by default. Use ChainTokenExtractors to accept the token from more sources, e.g. a cookie too.
+func WithTokenExtractor(extractor TokenExtractor) ValidatorOption {
+	return func(c *ValidatorConfig) {
+		c.tokenExtractor = extractor
+	}
+}
+
+// WithTokenCache caches the verified tokens, so the signature of a token is verified only once until it expires
+// or the TTL of the cache elapses. The cache is bounded, see the TokenCacheOptions, and it belongs to the validator,
+// so the tokens verified by a validator are never accepted by another one.
+func WithTokenCache(opts ...TokenCacheOption) ValidatorOption {
+	return func(c *ValidatorConfig) {
+		c.tokenCacheOptions = append([]TokenCacheOption{}, opts...)
+	}
+}
+
+// WithRevocationChecker rejects the revoked tokens with ErrTokenRevoked, the checker is consulted after
+// the signature of the token was verified, and on every hit of the token cache. See MemoryRevocationList
+// and NewRevocationStoreChecker.
+func WithRevocationChecker(checker RevocationChecker) ValidatorOption {
+	return func(c *ValidatorConfig) {
+		c.revocationChecker = checker
+	}
+}
+
 func withValidator(validator jwtValidator) ValidatorOption {
 	return func(c *ValidatorConfig) {
 		c.jwtValidator = validator
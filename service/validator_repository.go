@@ -5,14 +5,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"path"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/bitrise-io/bitrise-oauth/config"
 	"github.com/pkg/errors"
 )
 
+var (
+	// ErrUnknownIssuer is returned when there is no validator for the issuer (iss) of the token.
+	ErrUnknownIssuer = errors.New("there is no JWT validator for issuer")
+	// ErrIssuerNotAllowed is returned when registering an issuer that does not match the allowlist of the repository.
+	ErrIssuerNotAllowed = errors.New("issuer is not allowed")
+)
+
 // JwtValidatorRepository contains a set of JWT validators and can return the appropriate one for a given request or raw JWT
 //
-// The request must contain a valid JWT in the Authorization header ("Authorization: Bearer <token>")
+// The request must contain a valid JWT in the Authorization header ("Authorization: Bearer <token>"),
+// or in the source of the TokenExtractor set with WithRepositoryTokenExtractor
 // The validator is selected based on the "iss" claim in the JWT
 //go:generate moq -out mocks/jwtvalidatorrepository_mock.go -pkg service_test . JwtValidatorRepository
 type JwtValidatorRepository interface {
@@ -22,26 +35,181 @@ type JwtValidatorRepository interface {
 	GetJwtValidatorForRawToken(rawJwt string) (Validator, string, error)
 }
 
-// DefaultJwtValidatorRepository ...
+// DefaultJwtValidatorRepository is a registry of the validators of the trusted issuers, it is safe for concurrent use,
+// so issuers can be registered and unregistered while the repository is serving requests.
 type DefaultJwtValidatorRepository struct {
-	JwtValidators map[string]Validator
+	mu      sync.RWMutex
+	entries map[string]issuerEntry
+
+	allowedIssuers     []string
+	issuersFile        string
+	reloadInterval     time.Duration
+	validatorOptions   []ValidatorOption
+	reloadErrorHandler func(error)
+	discovery          *issuerDiscovery
+	discoveryClient    *http.Client
+	discoveryLimits    *discoveryLimits
+	tokenExtractor     TokenExtractor
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// issuerEntry is a registered validator, fromFile is set for the entries created from the issuers file,
+// only those are updated or removed when the file is reloaded. The discovered entries are replaced
+// by the issuers file if it contains their issuer.
+type issuerEntry struct {
+	validator  Validator
+	fromFile   bool
+	discovered bool
+	config     config.ValidatorConfig
 }
 
 // NewJwtValidatorRepository Creates a new JwtValidatorContainer that holds a set of validators associated with their issuer (iss)
 func NewJwtValidatorRepository(jwtValidators map[string]Validator) JwtValidatorRepository {
+	vr := newJwtValidatorRepository()
+	for iss, validator := range jwtValidators {
+		vr.entries[iss] = issuerEntry{validator: validator}
+	}
+
+	return vr
+}
+
+// NewJwtValidatorRegistry creates an empty repository, the issuers can be added with Register and RegisterIssuer,
+// or loaded from a file with WithIssuersFile. It returns an error if an allowlist pattern is malformed
+// or the issuers file cannot be loaded.
+func NewJwtValidatorRegistry(opts ...JwtValidatorRepositoryOption) (*DefaultJwtValidatorRepository, error) {
+	vr := newJwtValidatorRepository()
+	for _, opt := range opts {
+		opt(vr)
+	}
+
+	for _, pattern := range vr.allowedIssuers {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid issuer pattern %q: %w", pattern, err)
+		}
+	}
+
+	if vr.discovery != nil {
+		if len(vr.allowedIssuers) == 0 {
+			return nil, errors.New("issuer discovery requires an allowlist (WithAllowedIssuers)")
+		}
+		if vr.discoveryClient != nil {
+			vr.discovery.client = vr.discoveryClient
+		}
+		if vr.discoveryLimits != nil {
+			vr.discovery.setLimits(*vr.discoveryLimits)
+		}
+	}
+
+	if vr.issuersFile != "" {
+		modTime, err := vr.reloadIssuersFile()
+		if err != nil {
+			return nil, err
+		}
+
+		if vr.reloadInterval > 0 {
+			go vr.watchIssuersFile(modTime)
+		}
+	}
+
+	return vr, nil
+}
+
+func newJwtValidatorRepository() *DefaultJwtValidatorRepository {
 	return &DefaultJwtValidatorRepository{
-		JwtValidators: jwtValidators,
+		entries:            map[string]issuerEntry{},
+		reloadErrorHandler: func(error) {},
+		tokenExtractor:     AuthorizationHeaderExtractor(),
+		stop:               make(chan struct{}),
+	}
+}
+
+// Register adds the validator of the issuer, replacing the previous one if the issuer was already registered.
+// It returns ErrIssuerNotAllowed if the issuer does not match the allowlist.
+func (vr *DefaultJwtValidatorRepository) Register(issuer string, validator Validator) error {
+	if !vr.IssuerAllowed(issuer) {
+		return fmt.Errorf("%w: %s", ErrIssuerNotAllowed, issuer)
+	}
+
+	vr.mu.Lock()
+	defer vr.mu.Unlock()
+
+	vr.entries[issuer] = issuerEntry{validator: validator}
+
+	return nil
+}
+
+// RegisterIssuer creates and registers the validator of the issuer with its own audience configuration.
+// Unless the options set an other one, the keys are fetched from the Keycloak JWKS endpoint of the issuer
+// (<issuer>/protocol/openid-connect/certs).
+func (vr *DefaultJwtValidatorRepository) RegisterIssuer(issuer string, audienceConfig config.AudienceConfig, opts ...ValidatorOption) error {
+	if !vr.IssuerAllowed(issuer) {
+		return fmt.Errorf("%w: %s", ErrIssuerNotAllowed, issuer)
+	}
+
+	validatorOpts := []ValidatorOption{WithIssuer(issuer), WithJWKSUrl(issuerJWKSURL(issuer))}
+	validatorOpts = append(validatorOpts, vr.validatorOptions...)
+	validatorOpts = append(validatorOpts, opts...)
+
+	return vr.Register(issuer, NewValidator(audienceConfig, validatorOpts...))
+}
+
+// Unregister removes the validator of the issuer, it is a no-op if the issuer is not registered.
+func (vr *DefaultJwtValidatorRepository) Unregister(issuer string) {
+	vr.mu.Lock()
+	defer vr.mu.Unlock()
+
+	delete(vr.entries, issuer)
+}
+
+// Issuers returns the registered issuers in alphabetical order.
+func (vr *DefaultJwtValidatorRepository) Issuers() []string {
+	vr.mu.RLock()
+	defer vr.mu.RUnlock()
+
+	issuers := make([]string, 0, len(vr.entries))
+	for iss := range vr.entries {
+		issuers = append(issuers, iss)
+	}
+	sort.Strings(issuers)
+
+	return issuers
+}
+
+// IssuerAllowed reports whether the issuer matches one of the allowlist patterns, every issuer is allowed
+// if there is no allowlist.
+func (vr *DefaultJwtValidatorRepository) IssuerAllowed(issuer string) bool {
+	if len(vr.allowedIssuers) == 0 {
+		return true
+	}
+
+	for _, pattern := range vr.allowedIssuers {
+		if matched, _ := path.Match(pattern, issuer); matched {
+			return true
+		}
 	}
+
+	return false
+}
+
+// Close stops watching the issuers file, the registered validators remain usable.
+func (vr *DefaultJwtValidatorRepository) Close() error {
+	vr.closeOnce.Do(func() {
+		close(vr.stop)
+	})
+
+	return nil
 }
 
 // GetJwtValidatorForRequest ...
 func (vr *DefaultJwtValidatorRepository) GetJwtValidatorForRequest(r *http.Request) (Validator, string, error) {
-	rawJwt := strings.Split(strings.TrimSpace(r.Header.Get("Authorization")), "Bearer ")
-	if len(rawJwt) != 2 {
-		return nil, "", errors.New("failed to read JWT from header")
+	rawJwt, err := extractRawToken(vr.tokenExtractor, r)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read JWT from the request: %w", err)
 	}
 
-	return vr.GetJwtValidatorForRawToken(rawJwt[1])
+	return vr.GetJwtValidatorForRawToken(rawJwt)
 }
 
 // GetJwtValidatorForRawToken ...
@@ -51,9 +219,21 @@ func (vr *DefaultJwtValidatorRepository) GetJwtValidatorForRawToken(rawJwt strin
 		return nil, "", errors.Wrap(err, "failed to get issuer form the JWT")
 	}
 
-	validator := vr.JwtValidators[iss]
-	if validator == nil {
-		return nil, iss, fmt.Errorf("there is no JWT validator for issuer: %s", iss)
+	vr.mu.RLock()
+	entry, ok := vr.entries[iss]
+	vr.mu.RUnlock()
+
+	if ok {
+		return entry.validator, iss, nil
+	}
+
+	if vr.discovery == nil {
+		return nil, iss, fmt.Errorf("%w: %s", ErrUnknownIssuer, iss)
+	}
+
+	validator, err := vr.discoverValidator(iss)
+	if err != nil {
+		return nil, iss, fmt.Errorf("%w: %s: %w", ErrUnknownIssuer, iss, err)
 	}
 
 	return validator, iss, nil
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bitrise-io/bitrise-oauth/config"
+)
+
+// JwtValidatorRepositoryOption ...
+type JwtValidatorRepositoryOption func(vr *DefaultJwtValidatorRepository)
+
+// WithAllowedIssuers restricts the issuers that can be registered to the ones matching any of the patterns.
+// The patterns use the path.Match syntax, where * does not match /, for example
+// "https://auth.example.com/auth/realms/*" allows every realm of the host.
+func WithAllowedIssuers(patterns ...string) JwtValidatorRepositoryOption {
+	return func(vr *DefaultJwtValidatorRepository) {
+		vr.allowedIssuers = append(vr.allowedIssuers, patterns...)
+	}
+}
+
+// WithIssuersFile loads the issuers from a YAML or JSON file (see config.IssuersConfig).
+// If reloadInterval is positive, the file is checked for changes in the background with that interval
+// and the issuers are updated when it changes, until the repository is closed.
+func WithIssuersFile(path string, reloadInterval time.Duration) JwtValidatorRepositoryOption {
+	return func(vr *DefaultJwtValidatorRepository) {
+		vr.issuersFile = path
+		vr.reloadInterval = reloadInterval
+	}
+}
+
+// WithIssuerValidatorOptions sets options applied to every validator created by the repository,
+// for example WithTracerProvider or WithTimeout.
+func WithIssuerValidatorOptions(opts ...ValidatorOption) JwtValidatorRepositoryOption {
+	return func(vr *DefaultJwtValidatorRepository) {
+		vr.validatorOptions = append(vr.validatorOptions, opts...)
+	}
+}
+
+// WithReloadErrorHandler sets a function called when the issuers file cannot be reloaded,
+// the previously loaded issuers stay registered in that case.
+func WithReloadErrorHandler(handler func(error)) JwtValidatorRepositoryOption {
+	return func(vr *DefaultJwtValidatorRepository) {
+		vr.reloadErrorHandler = handler
+	}
+}
+
+// WithIssuerDiscovery creates the validators of the allowed but not registered issuers on demand,
+// from their OIDC discovery document (<issuer>/.well-known/openid-configuration). The tokens of the discovered
+// issuers must contain one of the audiences of audienceConfig. Only the HTTPS issuers matching the allowlist
+// are contacted, so WithAllowedIssuers is required. Failed discoveries are cached for failureTTL.
+func WithIssuerDiscovery(audienceConfig config.AudienceConfig, failureTTL time.Duration) JwtValidatorRepositoryOption {
+	return func(vr *DefaultJwtValidatorRepository) {
+		vr.discovery = newIssuerDiscovery(audienceConfig, failureTTL)
+	}
+}
+
+// WithDiscoveryHTTPClient sets the HTTP client used to fetch the discovery documents. By default a client
+// with a 10 seconds timeout is used, that does not follow redirects.
+func WithDiscoveryHTTPClient(client *http.Client) JwtValidatorRepositoryOption {
+	return func(vr *DefaultJwtValidatorRepository) {
+		vr.discoveryClient = client
+	}
+}
+
+// WithDiscoveryLimits limits the issuer discoveries of all the issuers, because the issuers to discover are taken
+// from tokens that are not verified yet. At most maxConcurrent discoveries run at the same time (4 by default),
+// at most maxPerMinute discoveries are started per minute (60 by default), and at most maxCachedFailures failed
+// issuers are cached (1024 by default). The lookups over the limits fail with ErrIssuerDiscoveryLimited.
+func WithDiscoveryLimits(maxConcurrent, maxPerMinute, maxCachedFailures int) JwtValidatorRepositoryOption {
+	return func(vr *DefaultJwtValidatorRepository) {
+		vr.discoveryLimits = &discoveryLimits{
+			maxConcurrent:     maxConcurrent,
+			maxPerMinute:      maxPerMinute,
+			maxCachedFailures: maxCachedFailures,
+		}
+	}
+}
+
+// WithRepositoryTokenExtractor sets where GetJwtValidatorForRequest reads the token from, the Authorization header
+// (AuthorizationHeaderExtractor) by default. It is applied to the validators created by the repository too,
+// the validators passed to Register or NewJwtValidatorRepository need their own WithTokenExtractor.
+func WithRepositoryTokenExtractor(extractor TokenExtractor) JwtValidatorRepositoryOption {
+	return func(vr *DefaultJwtValidatorRepository) {
+		vr.tokenExtractor = extractor
+		vr.validatorOptions = append(vr.validatorOptions, WithTokenExtractor(extractor))
+	}
+}
@@ -3,11 +3,16 @@ package service
 import (
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/bitrise-io/bitrise-oauth/config"
 	"github.com/bitrise-io/bitrise-oauth/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -84,5 +89,166 @@ func Test_GetJwtValidatorForRequest_GivenInvalidAuthorizationHeader_ReturnsError
 	request.Header.Add("Authorization", "InvalidHeader")
 
 	_, _, err = vr.GetJwtValidatorForRequest(request)
-	assert.EqualError(t, err, "failed to read JWT from header")
+	assert.ErrorContains(t, err, "failed to read JWT from the request")
+	assert.ErrorIs(t, err, ErrMissingToken)
+}
+
+func Test_GivenRegistry_WhenIssuersAreRegisteredAndUnregistered_ThenExpectTheValidatorsToBeUpdated(t *testing.T) {
+	// Given
+	vr, err := NewJwtValidatorRegistry()
+	require.NoError(t, err)
+	validator := NewValidator(config.NewAudienceConfig("bitrise-api"), WithRealm("bitrise-services"))
+
+	// When
+	require.NoError(t, vr.Register(tokenIssuerServiceIssuer, validator))
+	require.NoError(t, vr.Register(authServiceIssuer, validator))
+
+	// Then
+	assert.Equal(t, []string{authServiceIssuer, tokenIssuerServiceIssuer}, vr.Issuers())
+	v, iss, err := vr.GetJwtValidatorForRawToken(mocks.RawMockToken)
+	require.NoError(t, err)
+	assert.Equal(t, tokenIssuerServiceIssuer, iss)
+	assert.Equal(t, validator, v)
+
+	// When
+	vr.Unregister(tokenIssuerServiceIssuer)
+
+	// Then
+	assert.Equal(t, []string{authServiceIssuer}, vr.Issuers())
+	_, _, err = vr.GetJwtValidatorForRawToken(mocks.RawMockToken)
+	assert.ErrorIs(t, err, ErrUnknownIssuer)
+}
+
+func Test_GivenAllowlist_WhenIssuersAreRegistered_ThenExpectOnlyTheMatchingOnesToBeAccepted(t *testing.T) {
+	// Given
+	vr, err := NewJwtValidatorRegistry(WithAllowedIssuers("https://auth.bitrise.io/auth/realms/*"))
+	require.NoError(t, err)
+	validator := NewValidator(config.NewAudienceConfig("bitrise-api"))
+
+	// When
+	allowedErr := vr.Register(authServiceIssuer, validator)
+	otherHostErr := vr.Register(tokenIssuerServiceIssuer, validator)
+	nestedPathErr := vr.Register("https://auth.bitrise.io/auth/realms/bitrise-services/nested", validator)
+
+	// Then
+	assert.NoError(t, allowedErr)
+	assert.ErrorIs(t, otherHostErr, ErrIssuerNotAllowed)
+	assert.ErrorIs(t, nestedPathErr, ErrIssuerNotAllowed)
+	assert.Equal(t, []string{authServiceIssuer}, vr.Issuers())
+}
+
+func Test_GivenMalformedAllowlistPattern_WhenRegistryIsCreated_ThenExpectError(t *testing.T) {
+	// When
+	_, err := NewJwtValidatorRegistry(WithAllowedIssuers("https://auth.bitrise.io/[realms"))
+
+	// Then
+	assert.ErrorContains(t, err, `invalid issuer pattern "https://auth.bitrise.io/[realms"`)
+}
+
+func Test_GivenRegistry_WhenIssuerIsRegisteredWithAudiences_ThenExpectItsOwnValidatorConfiguration(t *testing.T) {
+	// Given
+	vr, err := NewJwtValidatorRegistry(WithIssuerValidatorOptions(WithTimeout(5 * time.Second)))
+	require.NoError(t, err)
+
+	// When
+	require.NoError(t, vr.RegisterIssuer(tokenIssuerServiceIssuer, config.NewAudienceConfig("addons-api")))
+
+	// Then
+	v, _, err := vr.GetJwtValidatorForRawToken(mocks.RawMockToken)
+	require.NoError(t, err)
+	validatorConfig := v.(*ValidatorConfig)
+	assert.Equal(t, tokenIssuerServiceIssuer, validatorConfig.issuer)
+	assert.Equal(t, tokenIssuerServiceIssuer+"/protocol/openid-connect/certs", validatorConfig.jwksURL)
+	assert.Equal(t, []string{"addons-api"}, validatorConfig.audience.All())
+	assert.Equal(t, 5*time.Second, validatorConfig.timeout)
+}
+
+func Test_GivenIssuersFile_WhenItChanges_ThenExpectTheIssuersToBeReloaded(t *testing.T) {
+	// Given
+	issuersPath := filepath.Join(t.TempDir(), "issuers.yaml")
+	writeIssuersFile(t, issuersPath, time.Now().Add(-time.Hour), authServiceIssuer)
+	reloadErrs := make(chan error, 10)
+	vr, err := NewJwtValidatorRegistry(
+		WithIssuersFile(issuersPath, 10*time.Millisecond),
+		WithReloadErrorHandler(func(err error) { reloadErrs <- err }))
+	require.NoError(t, err)
+	defer vr.Close() //nolint: errcheck
+
+	manualValidator := NewValidator(config.NewAudienceConfig("bitrise-api"))
+	require.NoError(t, vr.Register("https://manual.bitrise.io", manualValidator))
+	assert.Equal(t, []string{authServiceIssuer, "https://manual.bitrise.io"}, vr.Issuers())
+
+	// When
+	writeIssuersFile(t, issuersPath, time.Now(), tokenIssuerServiceIssuer)
+
+	// Then
+	assert.Eventually(t, func() bool {
+		issuers := vr.Issuers()
+		return len(issuers) == 2 && issuers[1] == tokenIssuerServiceIssuer
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"https://manual.bitrise.io", tokenIssuerServiceIssuer}, vr.Issuers())
+
+	// When
+	require.NoError(t, os.WriteFile(issuersPath, []byte("issuers: [{issuer: 42}]"), 0o600))
+	require.NoError(t, os.Chtimes(issuersPath, time.Now().Add(time.Hour), time.Now().Add(time.Hour)))
+
+	// Then
+	select {
+	case err := <-reloadErrs:
+		assert.ErrorContains(t, err, "config: invalid issuers configuration")
+	case <-time.After(time.Second):
+		t.Fatal("the reload error was not reported")
+	}
+	assert.Equal(t, []string{"https://manual.bitrise.io", tokenIssuerServiceIssuer}, vr.Issuers())
+}
+
+func Test_GivenIssuersFileWithIssuerOutsideOfAllowlist_WhenRegistryIsCreated_ThenExpectError(t *testing.T) {
+	// Given
+	issuersPath := filepath.Join(t.TempDir(), "issuers.yaml")
+	writeIssuersFile(t, issuersPath, time.Now(), tokenIssuerServiceIssuer)
+
+	// When
+	_, err := NewJwtValidatorRegistry(
+		WithAllowedIssuers("https://auth.bitrise.io/auth/realms/*"),
+		WithIssuersFile(issuersPath, 0))
+
+	// Then
+	assert.ErrorIs(t, err, ErrIssuerNotAllowed)
+}
+
+func Test_GivenRegistry_WhenUsedConcurrently_ThenExpectNoDataRace(t *testing.T) {
+	// Given
+	vr, err := NewJwtValidatorRegistry()
+	require.NoError(t, err)
+	validator := NewValidator(config.NewAudienceConfig("bitrise-api"))
+
+	// When
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, vr.Register(tokenIssuerServiceIssuer, validator))
+			vr.Unregister(tokenIssuerServiceIssuer)
+		}()
+		go func() {
+			defer wg.Done()
+			_, _, _ = vr.GetJwtValidatorForRawToken(mocks.RawMockToken)
+			_ = vr.Issuers()
+		}()
+	}
+	wg.Wait()
+
+	// Then
+	assert.Empty(t, vr.Issuers())
+}
+
+func writeIssuersFile(t *testing.T, path string, modTime time.Time, issuers ...string) {
+	content := "issuers:\n"
+	for _, iss := range issuers {
+		content += fmt.Sprintf("  - issuer: %s\n    audiences: [bitrise-api]\n", iss)
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/labstack/echo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"github.com/go-jose/go-jose/v4"
 	"github.com/go-jose/go-jose/v4/jwt"
@@ -172,6 +173,7 @@ func givenMockEchoErrorWriter(err error) *mocks.ErrorWriter {
 func createValidator(mockJWTValidator jwtValidator, mockSecretProvider auth0.SecretProvider) Validator {
 	validator := NewValidator(
 		config.NewAudienceConfig("test_audience"),
+		WithIssuer(tokenIssuerServiceIssuer),
 		withValidator(mockJWTValidator),
 		withSecretProvider(mockSecretProvider),
 	)
@@ -210,6 +212,7 @@ func Test_AudienceClaimValidation(t *testing.T) {
 		name           string
 		tokenAudiences []string
 		inputAudiences []string
+		matchMode      config.AudienceMatchMode
 		expectedError  error
 	}{
 		{
@@ -236,6 +239,26 @@ func Test_AudienceClaimValidation(t *testing.T) {
 			inputAudiences: []string{"aud1", "aud2"},
 			expectedError:  jwt.ErrInvalidAudience,
 		},
+		{
+			name:           "Given the all-of mode when the token misses one of the audiences then expect an error",
+			tokenAudiences: []string{"aud1", "aud3"},
+			inputAudiences: []string{"aud1", "aud2"},
+			matchMode:      config.AudienceMatchAllOf,
+			expectedError:  jwt.ErrInvalidAudience,
+		},
+		{
+			name:           "Given the exact-set mode when the token has an extra audience then expect an error",
+			tokenAudiences: []string{"aud1", "aud2", "aud3"},
+			inputAudiences: []string{"aud1", "aud2"},
+			matchMode:      config.AudienceMatchExactSet,
+			expectedError:  jwt.ErrInvalidAudience,
+		},
+		{
+			name:           "Given the pattern mode when a tenant audience matches the pattern then expect no error",
+			tokenAudiences: []string{"bitrise-api-tenant1"},
+			inputAudiences: []string{"bitrise-api-*"},
+			matchMode:      config.AudienceMatchPattern,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -245,7 +268,7 @@ func Test_AudienceClaimValidation(t *testing.T) {
 			request := testToken.newRequest()
 
 			validator := NewValidator(
-				config.NewAudienceConfig(testCase.inputAudiences[0], testCase.inputAudiences[1:]...),
+				config.NewAudienceConfigFromAudiences(testCase.inputAudiences).WithMatchMode(testCase.matchMode),
 				WithIssuer(defaultIssuer),
 				withSecretProvider(defaultSecretProvider),
 			)
@@ -254,11 +277,145 @@ func Test_AudienceClaimValidation(t *testing.T) {
 			err := validator.ValidateRequest(request)
 
 			// Then
-			assert.Equal(t, testCase.expectedError, err)
+			if testCase.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, testCase.expectedError)
+			}
 		})
 	}
 }
 
+func Test_GivenTokenWithMalformedAudience_WhenRequestIsValidated_ThenExpectAMalformedTokenError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		audience interface{}
+	}{
+		{"number", 42},
+		{"object", map[string]string{"aud": "audience"}},
+		{"list with a number", []interface{}{defaultAudience[0], 42}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Given
+			request := newRequestWithClaims(map[string]interface{}{
+				"iss": defaultIssuer,
+				"aud": testCase.audience,
+				"exp": time.Now().Add(time.Hour).Unix(),
+			})
+			validator := NewValidator(config.NewAudienceConfig(defaultAudience[0]),
+				WithIssuer(defaultIssuer),
+				withSecretProvider(defaultSecretProvider))
+
+			// When
+			var err error
+			assert.NotPanics(t, func() {
+				err = validator.ValidateRequest(request)
+			})
+
+			// Then
+			assert.ErrorIs(t, err, ErrMalformedToken)
+		})
+	}
+}
+
+func Test_GivenTokenWithUnexpectedTypesInOptionalClaims_WhenRequestIsValidated_ThenExpectItToBeValid(t *testing.T) {
+	// Given
+	request := newRequestWithClaims(map[string]interface{}{
+		"iss":   defaultIssuer,
+		"aud":   defaultAudience,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": []string{"a", "b"},
+		"sid":   42,
+	})
+	validator := NewValidator(config.NewAudienceConfig(defaultAudience[0]),
+		WithIssuer(defaultIssuer),
+		withSecretProvider(defaultSecretProvider))
+
+	// When
+	token, err := validator.ValidateRequestAndReturnToken(request)
+
+	// Then
+	require.NoError(t, err)
+	_, err = token.StandardClaims()
+	assert.Error(t, err)
+	payload, err := token.Payload()
+	require.NoError(t, err)
+	assert.Equal(t, float64(42), payload["sid"])
+}
+
+func Test_GivenMalformedAudienceClaim_WhenAudiencesAreValidated_ThenExpectAnErrorInsteadOfAPanic(t *testing.T) {
+	// Given
+	validator := ValidatorConfig{audience: config.NewAudienceConfig(defaultAudience[0])}
+	token := givenTokenWithClaims(map[string]interface{}{"aud": []interface{}{defaultAudience[0], 42}})
+
+	// When
+	err := validator.validateAudiences(&token)
+
+	// Then
+	assert.ErrorIs(t, err, jwt.ErrInvalidAudience)
+}
+
+func Test_GivenValidatorConfig_WhenValidatorIsCreated_ThenExpectTheConfigToBeUsed(t *testing.T) {
+	// Given
+	leeway := 2 * time.Minute
+	cfg := config.ValidatorConfig{
+		BaseURL:    "https://auth.example.com",
+		Realm:      "my-realm",
+		Algorithms: []string{"RS256", "ES256"},
+		Audiences:  []string{"aud1", "aud2"},
+		Timeout:    5 * time.Second,
+		Leeway:     &leeway,
+	}
+
+	// When
+	validator, err := NewValidatorFromConfig(cfg, withSecretProvider(defaultSecretProvider))
+
+	// Then
+	require.NoError(t, err)
+	validatorConfig := validator.(*ValidatorConfig)
+	assert.Equal(t, "https://auth.example.com/auth/realms/my-realm", validatorConfig.issuer)
+	assert.Equal(t, []jose.SignatureAlgorithm{jose.RS256, jose.ES256}, validatorConfig.signatureAlgorithms)
+	assert.Equal(t, []string{"aud1", "aud2"}, validatorConfig.audience.All())
+	assert.Equal(t, 5*time.Second, validatorConfig.timeout)
+	assert.Equal(t, 2*time.Minute, validatorConfig.timeClaims.leeway)
+}
+
+func Test_GivenValidatorConfigWithZeroLeeway_WhenValidatorIsCreated_ThenExpectTheLeewayToBeTurnedOff(t *testing.T) {
+	// Given
+	leeway := time.Duration(0)
+	cfg := config.ValidatorConfig{Audiences: []string{"aud1"}, Leeway: &leeway}
+
+	// When
+	validator, err := NewValidatorFromConfig(cfg, withSecretProvider(defaultSecretProvider))
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), validator.(*ValidatorConfig).timeClaims.leeway)
+}
+
+func Test_GivenValidatorConfigWithoutLeeway_WhenValidatorIsCreated_ThenExpectTheDefaultLeeway(t *testing.T) {
+	// Given
+	cfg := config.ValidatorConfig{Audiences: []string{"aud1"}}
+
+	// When
+	validator, err := NewValidatorFromConfig(cfg, withSecretProvider(defaultSecretProvider))
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, jwt.DefaultLeeway, validator.(*ValidatorConfig).timeClaims.leeway)
+}
+
+func Test_GivenInvalidValidatorConfig_WhenValidatorIsCreated_ThenExpectAnError(t *testing.T) {
+	// When
+	_, err := NewValidatorFromConfig(config.ValidatorConfig{Issuer: "issuer"})
+
+	// Then
+	assert.ErrorContains(t, err, "at least one audience (OAUTH_VALIDATOR_AUDIENCES) is required")
+	assert.ErrorContains(t, err, `issuer must be an absolute http(s) URL, got "issuer"`)
+}
+
 func newTestTokenConfigWithAudiences(audiences []string) testTokenConfig {
 	return testTokenConfig{
 		audiences,
@@ -0,0 +1,21 @@
+// Package tokencontext stores the raw (encoded) token of the caller in a context. It is shared by the service
+// package, whose middlewares store the token, and the client package, which forwards it to downstream services,
+// so the clients don't depend on the server-side validation.
+package tokencontext
+
+import "context"
+
+type contextKey int
+
+const rawTokenContextKey contextKey = iota
+
+// WithRawToken returns a copy of the context that holds the raw (encoded) token of the caller.
+func WithRawToken(ctx context.Context, rawToken string) context.Context {
+	return context.WithValue(ctx, rawTokenContextKey, rawToken)
+}
+
+// RawToken returns the raw (encoded) token of the caller, it reports false if the context holds no token.
+func RawToken(ctx context.Context) (string, bool) {
+	rawToken, ok := ctx.Value(rawTokenContextKey).(string)
+	return rawToken, ok && rawToken != ""
+}
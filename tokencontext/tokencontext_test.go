@@ -0,0 +1,23 @@
+package tokencontext
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GivenContextWithRawToken_WhenRawTokenIsRead_ThenExpectTheToken(t *testing.T) {
+	// Given
+	ctx := WithRawToken(context.Background(), "raw-token")
+
+	// When
+	rawToken, ok := RawToken(ctx)
+
+	// Then
+	assert.True(t, ok)
+	assert.Equal(t, "raw-token", rawToken)
+
+	_, ok = RawToken(WithRawToken(context.Background(), ""))
+	assert.False(t, ok)
+}